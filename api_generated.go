@@ -0,0 +1,956 @@
+// Code generated by freeipa-gen from the API schema. DO NOT EDIT.
+
+package freeipa
+
+import (
+	"context"
+	"time"
+)
+
+// Fingerprint of the schema the command methods were generated from.
+const GeneratedSchemaFingerprint = "d3a0b2fb7c6e5d48"
+
+// Attributes of a group entry.
+type Group struct {
+	// Distinguished name.
+	DN string `ipa:"dn"`
+	// Group name.
+	GroupName string `ipa:"cn"`
+	// Group description.
+	Desc string `ipa:"description"`
+	// GID (use this option to set it manually).
+	GID int `ipa:"gidnumber"`
+	// Member users.
+	MemberUser []string `ipa:"member_user"`
+	// Member groups.
+	MemberGroup []string `ipa:"member_group"`
+	// Member of groups.
+	MemberofGroup []string `ipa:"memberof_group"`
+}
+
+// Attributes of a host entry.
+type Host struct {
+	// Distinguished name.
+	DN string `ipa:"dn"`
+	// Host name.
+	Hostname string `ipa:"fqdn"`
+	// A description of this host.
+	Desc string `ipa:"description"`
+	// Host locality (e.g. "Baltimore, MD").
+	Locality string `ipa:"l"`
+	// Host location (e.g. "Lab 2").
+	Location string `ipa:"nshostlocation"`
+	// Host operating system and version (e.g. "Fedora 9").
+	OS string `ipa:"nsosversion"`
+	// Principal name.
+	Principal []string `ipa:"krbprincipalname"`
+	// Base-64 encoded host certificate.
+	Certificate [][]byte `ipa:"usercertificate"`
+	// SSH public key.
+	Sshpubkey []string `ipa:"ipasshpubkey"`
+	// Keytab.
+	HasKeytab bool `ipa:"has_keytab"`
+	// Password.
+	HasPassword bool `ipa:"has_password"`
+	// Add the host to DNS with this IP address.
+	IPAddress string `ipa:"ip_address"`
+}
+
+// Attributes of a user entry.
+type User struct {
+	// Distinguished name.
+	DN string `ipa:"dn"`
+	// User login.
+	Login string `ipa:"uid"`
+	// First name.
+	First string `ipa:"givenname"`
+	// Last name.
+	Last string `ipa:"sn"`
+	// Full name.
+	Cn string `ipa:"cn"`
+	// Display name.
+	Displayname string `ipa:"displayname"`
+	// Initials.
+	Initials string `ipa:"initials"`
+	// Home directory.
+	Homedir string `ipa:"homedirectory"`
+	// GECOS.
+	Gecos string `ipa:"gecos"`
+	// Login shell.
+	Shell string `ipa:"loginshell"`
+	// Principal alias.
+	Principal []string `ipa:"krbprincipalname"`
+	// Kerberos principal expiration.
+	PrincipalExpiration time.Time `ipa:"krbprincipalexpiration"`
+	// User password expiration.
+	PasswordExpiration time.Time `ipa:"krbpasswordexpiration"`
+	// Email address.
+	Email []string `ipa:"mail"`
+	// Prompt to set the user password.
+	Password string `ipa:"userpassword"`
+	// User ID Number (system will assign one if not provided).
+	UID int `ipa:"uidnumber"`
+	// Group ID Number.
+	Gidnumber int `ipa:"gidnumber"`
+	// Telephone Number.
+	Phone []string `ipa:"telephonenumber"`
+	// Job Title.
+	Title string `ipa:"title"`
+	// SSH public key.
+	Sshpubkey []string `ipa:"ipasshpubkey"`
+	// Base-64 encoded user certificate.
+	Certificate [][]byte `ipa:"usercertificate"`
+	// Account disabled.
+	Disabled bool `ipa:"nsaccountlock"`
+	// Member of groups.
+	MemberofGroup []string `ipa:"memberof_group"`
+	// Password.
+	HasPassword bool `ipa:"has_password"`
+	// Kerberos keys available.
+	HasKeytab bool `ipa:"has_keytab"`
+}
+
+// Arguments of group_add.
+type GroupAddArgs struct {
+	// Group name.
+	GroupName string
+}
+
+// Options of group_add.
+type GroupAddOptions struct {
+	// Group description.
+	Desc *string `ipa:"description"`
+	// GID (use this option to set it manually).
+	GID *int `ipa:"gidnumber"`
+	// Create as a non-POSIX group.
+	Nonposix *bool `ipa:"nonposix"`
+	// Allow adding external non-IPA members from trusted domains.
+	External *bool `ipa:"external"`
+	// Suppress processing of membership attributes.
+	NoMembers *bool `ipa:"no_members"`
+	// Retrieve and print all attributes from the server. Affects command output.
+	All *bool `ipa:"all"`
+	// Print entries as stored on the server. Only affects output format.
+	Raw *bool `ipa:"raw"`
+}
+
+// Result of group_add.
+type GroupAddResult struct {
+	// User-friendly description of action performed.
+	Summary string `ipa:"summary"`
+	Result  Group  `ipa:"result"`
+	// The primary_key value of the entry, e.g. 'jdoe' for a user.
+	Value string `ipa:"value"`
+}
+
+// Create a new group.
+func (c *Client) GroupAdd(ctx context.Context, args GroupAddArgs, options GroupAddOptions) (*GroupAddResult, error) {
+	params := []interface{}{args.GroupName}
+	resp, err := c.DoContext(ctx, NewRequest("group_add", params, options))
+	if err != nil {
+		return nil, err
+	}
+	result := new(GroupAddResult)
+	err = resp.DecodeOutput(result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Arguments of group_add_member.
+type GroupAddMemberArgs struct {
+	// Group name.
+	GroupName string
+}
+
+// Options of group_add_member.
+type GroupAddMemberOptions struct {
+	// users to add.
+	Users []string `ipa:"user,omitempty"`
+	// groups to add.
+	Groups []string `ipa:"group,omitempty"`
+	// Suppress processing of membership attributes.
+	NoMembers *bool `ipa:"no_members"`
+	// Retrieve and print all attributes from the server. Affects command output.
+	All *bool `ipa:"all"`
+	// Print entries as stored on the server. Only affects output format.
+	Raw *bool `ipa:"raw"`
+}
+
+// Result of group_add_member.
+type GroupAddMemberResult struct {
+	Result Group `ipa:"result"`
+	// Members that could not be added.
+	Failed map[string]interface{} `ipa:"failed"`
+	// Number of members added.
+	Completed int `ipa:"completed"`
+}
+
+// Add members to a group.
+func (c *Client) GroupAddMember(ctx context.Context, args GroupAddMemberArgs, options GroupAddMemberOptions) (*GroupAddMemberResult, error) {
+	params := []interface{}{args.GroupName}
+	resp, err := c.DoContext(ctx, NewRequest("group_add_member", params, options))
+	if err != nil {
+		return nil, err
+	}
+	result := new(GroupAddMemberResult)
+	err = resp.DecodeOutput(result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Arguments of group_del.
+type GroupDelArgs struct {
+	// Group name.
+	GroupName string
+}
+
+// Options of group_del.
+type GroupDelOptions struct {
+	// Continuous mode: Don't stop on errors.
+	Continue *bool `ipa:"continue"`
+}
+
+// Result of group_del.
+type GroupDelResult struct {
+	// User-friendly description of action performed.
+	Summary string `ipa:"summary"`
+	// List of deletions that failed.
+	Result map[string]interface{} `ipa:"result"`
+	// The primary_key value of the entry, e.g. 'jdoe' for a user.
+	Value []string `ipa:"value"`
+}
+
+// Delete group.
+func (c *Client) GroupDel(ctx context.Context, args GroupDelArgs, options GroupDelOptions) (*GroupDelResult, error) {
+	params := []interface{}{args.GroupName}
+	resp, err := c.DoContext(ctx, NewRequest("group_del", params, options))
+	if err != nil {
+		return nil, err
+	}
+	result := new(GroupDelResult)
+	err = resp.DecodeOutput(result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Arguments of group_find.
+type GroupFindArgs struct {
+	// A string searched in all relevant object attributes.
+	Criteria *string
+}
+
+// Options of group_find.
+type GroupFindOptions struct {
+	// Group name.
+	GroupName *string `ipa:"cn"`
+	// Group description.
+	Desc *string `ipa:"description"`
+	// Maximum number of entries returned (0 is unlimited).
+	Sizelimit *int `ipa:"sizelimit"`
+	// Search for groups with these member users.
+	Users []string `ipa:"user,omitempty"`
+	// Results should contain primary key attribute only ("group-name").
+	PkeyOnly *bool `ipa:"pkey_only"`
+	// Suppress processing of membership attributes.
+	NoMembers *bool `ipa:"no_members"`
+	// Retrieve and print all attributes from the server. Affects command output.
+	All *bool `ipa:"all"`
+	// Print entries as stored on the server. Only affects output format.
+	Raw *bool `ipa:"raw"`
+}
+
+// Result of group_find.
+type GroupFindResult struct {
+	// User-friendly description of action performed.
+	Summary string  `ipa:"summary"`
+	Result  []Group `ipa:"result"`
+	// Number of entries returned.
+	Count int `ipa:"count"`
+	// True if not all results were returned.
+	Truncated bool `ipa:"truncated"`
+}
+
+// Search for groups.
+func (c *Client) GroupFind(ctx context.Context, args GroupFindArgs, options GroupFindOptions) (*GroupFindResult, error) {
+	params := []interface{}{}
+	if args.Criteria != nil {
+		params = append(params, *args.Criteria)
+	}
+	resp, err := c.DoContext(ctx, NewRequest("group_find", params, options))
+	if err != nil {
+		return nil, err
+	}
+	result := new(GroupFindResult)
+	err = resp.DecodeOutput(result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Arguments of group_remove_member.
+type GroupRemoveMemberArgs struct {
+	// Group name.
+	GroupName string
+}
+
+// Options of group_remove_member.
+type GroupRemoveMemberOptions struct {
+	// users to remove.
+	Users []string `ipa:"user,omitempty"`
+	// groups to remove.
+	Groups []string `ipa:"group,omitempty"`
+	// Suppress processing of membership attributes.
+	NoMembers *bool `ipa:"no_members"`
+	// Retrieve and print all attributes from the server. Affects command output.
+	All *bool `ipa:"all"`
+	// Print entries as stored on the server. Only affects output format.
+	Raw *bool `ipa:"raw"`
+}
+
+// Result of group_remove_member.
+type GroupRemoveMemberResult struct {
+	Result Group `ipa:"result"`
+	// Members that could not be removed.
+	Failed map[string]interface{} `ipa:"failed"`
+	// Number of members removed.
+	Completed int `ipa:"completed"`
+}
+
+// Remove members from a group.
+func (c *Client) GroupRemoveMember(ctx context.Context, args GroupRemoveMemberArgs, options GroupRemoveMemberOptions) (*GroupRemoveMemberResult, error) {
+	params := []interface{}{args.GroupName}
+	resp, err := c.DoContext(ctx, NewRequest("group_remove_member", params, options))
+	if err != nil {
+		return nil, err
+	}
+	result := new(GroupRemoveMemberResult)
+	err = resp.DecodeOutput(result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Arguments of group_show.
+type GroupShowArgs struct {
+	// Group name.
+	GroupName string
+}
+
+// Options of group_show.
+type GroupShowOptions struct {
+	// Suppress processing of membership attributes.
+	NoMembers *bool `ipa:"no_members"`
+	// Retrieve and print all attributes from the server. Affects command output.
+	All *bool `ipa:"all"`
+	// Print entries as stored on the server. Only affects output format.
+	Raw *bool `ipa:"raw"`
+}
+
+// Result of group_show.
+type GroupShowResult struct {
+	// User-friendly description of action performed.
+	Summary string `ipa:"summary"`
+	Result  Group  `ipa:"result"`
+	// The primary_key value of the entry, e.g. 'jdoe' for a user.
+	Value string `ipa:"value"`
+}
+
+// Display information about a named group.
+func (c *Client) GroupShow(ctx context.Context, args GroupShowArgs, options GroupShowOptions) (*GroupShowResult, error) {
+	params := []interface{}{args.GroupName}
+	resp, err := c.DoContext(ctx, NewRequest("group_show", params, options))
+	if err != nil {
+		return nil, err
+	}
+	result := new(GroupShowResult)
+	err = resp.DecodeOutput(result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Arguments of host_add.
+type HostAddArgs struct {
+	// Host name.
+	Hostname string
+}
+
+// Options of host_add.
+type HostAddOptions struct {
+	// A description of this host.
+	Desc *string `ipa:"description"`
+	// Host locality (e.g. "Baltimore, MD").
+	Locality *string `ipa:"l"`
+	// Host operating system and version (e.g. "Fedora 9").
+	OS *string `ipa:"nsosversion"`
+	// Base-64 encoded host certificate.
+	Certificate [][]byte `ipa:"usercertificate,omitempty"`
+	// Generate a random password to be used in bulk enrollment.
+	Random *bool `ipa:"random"`
+	// force host name even if not in DNS.
+	Force *bool `ipa:"force"`
+	// skip reverse DNS detection.
+	NoReverse *bool `ipa:"no_reverse"`
+	// Add the host to DNS with this IP address.
+	IPAddress *string `ipa:"ip_address"`
+	// Suppress processing of membership attributes.
+	NoMembers *bool `ipa:"no_members"`
+	// Retrieve and print all attributes from the server. Affects command output.
+	All *bool `ipa:"all"`
+	// Print entries as stored on the server. Only affects output format.
+	Raw *bool `ipa:"raw"`
+}
+
+// Result of host_add.
+type HostAddResult struct {
+	// User-friendly description of action performed.
+	Summary string `ipa:"summary"`
+	Result  Host   `ipa:"result"`
+	// The primary_key value of the entry, e.g. 'jdoe' for a user.
+	Value string `ipa:"value"`
+}
+
+// Add a new host.
+func (c *Client) HostAdd(ctx context.Context, args HostAddArgs, options HostAddOptions) (*HostAddResult, error) {
+	params := []interface{}{args.Hostname}
+	resp, err := c.DoContext(ctx, NewRequest("host_add", params, options))
+	if err != nil {
+		return nil, err
+	}
+	result := new(HostAddResult)
+	err = resp.DecodeOutput(result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Arguments of host_del.
+type HostDelArgs struct {
+	// Host name.
+	Hostname []string
+}
+
+// Options of host_del.
+type HostDelOptions struct {
+	// Continuous mode: Don't stop on errors.
+	Continue *bool `ipa:"continue"`
+	// Remove A, AAAA, SSHFP and PTR records of the host(s) managed by IPA DNS.
+	Updatedns *bool `ipa:"updatedns"`
+}
+
+// Result of host_del.
+type HostDelResult struct {
+	// User-friendly description of action performed.
+	Summary string `ipa:"summary"`
+	// List of deletions that failed.
+	Result map[string]interface{} `ipa:"result"`
+	// The primary_key value of the entry, e.g. 'jdoe' for a user.
+	Value []string `ipa:"value"`
+}
+
+// Delete a host.
+func (c *Client) HostDel(ctx context.Context, args HostDelArgs, options HostDelOptions) (*HostDelResult, error) {
+	params := []interface{}{args.Hostname}
+	resp, err := c.DoContext(ctx, NewRequest("host_del", params, options))
+	if err != nil {
+		return nil, err
+	}
+	result := new(HostDelResult)
+	err = resp.DecodeOutput(result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Arguments of host_find.
+type HostFindArgs struct {
+	// A string searched in all relevant object attributes.
+	Criteria *string
+}
+
+// Options of host_find.
+type HostFindOptions struct {
+	// Host name.
+	Hostname *string `ipa:"fqdn"`
+	// A description of this host.
+	Desc *string `ipa:"description"`
+	// Maximum number of entries returned (0 is unlimited).
+	Sizelimit *int `ipa:"sizelimit"`
+	// Search for hosts with these member of host groups.
+	InHostgroups []string `ipa:"in_hostgroup,omitempty"`
+	// Results should contain primary key attribute only ("hostname").
+	PkeyOnly *bool `ipa:"pkey_only"`
+	// Suppress processing of membership attributes.
+	NoMembers *bool `ipa:"no_members"`
+	// Retrieve and print all attributes from the server. Affects command output.
+	All *bool `ipa:"all"`
+	// Print entries as stored on the server. Only affects output format.
+	Raw *bool `ipa:"raw"`
+}
+
+// Result of host_find.
+type HostFindResult struct {
+	// User-friendly description of action performed.
+	Summary string `ipa:"summary"`
+	Result  []Host `ipa:"result"`
+	// Number of entries returned.
+	Count int `ipa:"count"`
+	// True if not all results were returned.
+	Truncated bool `ipa:"truncated"`
+}
+
+// Search for hosts.
+func (c *Client) HostFind(ctx context.Context, args HostFindArgs, options HostFindOptions) (*HostFindResult, error) {
+	params := []interface{}{}
+	if args.Criteria != nil {
+		params = append(params, *args.Criteria)
+	}
+	resp, err := c.DoContext(ctx, NewRequest("host_find", params, options))
+	if err != nil {
+		return nil, err
+	}
+	result := new(HostFindResult)
+	err = resp.DecodeOutput(result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Arguments of host_show.
+type HostShowArgs struct {
+	// Host name.
+	Hostname string
+}
+
+// Options of host_show.
+type HostShowOptions struct {
+	// file to store certificate in.
+	Out *string `ipa:"out"`
+	// Suppress processing of membership attributes.
+	NoMembers *bool `ipa:"no_members"`
+	// Retrieve and print all attributes from the server. Affects command output.
+	All *bool `ipa:"all"`
+	// Print entries as stored on the server. Only affects output format.
+	Raw *bool `ipa:"raw"`
+}
+
+// Result of host_show.
+type HostShowResult struct {
+	// User-friendly description of action performed.
+	Summary string `ipa:"summary"`
+	Result  Host   `ipa:"result"`
+	// The primary_key value of the entry, e.g. 'jdoe' for a user.
+	Value string `ipa:"value"`
+}
+
+// Display information about a host.
+func (c *Client) HostShow(ctx context.Context, args HostShowArgs, options HostShowOptions) (*HostShowResult, error) {
+	params := []interface{}{args.Hostname}
+	resp, err := c.DoContext(ctx, NewRequest("host_show", params, options))
+	if err != nil {
+		return nil, err
+	}
+	result := new(HostShowResult)
+	err = resp.DecodeOutput(result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Options of ping.
+type PingOptions struct {
+}
+
+// Result of ping.
+type PingResult struct {
+	// User-friendly description of action performed.
+	Summary string `ipa:"summary"`
+}
+
+// Ping a remote server.
+func (c *Client) Ping(ctx context.Context, options PingOptions) (*PingResult, error) {
+	var params []interface{}
+	resp, err := c.DoContext(ctx, NewRequest("ping", params, options))
+	if err != nil {
+		return nil, err
+	}
+	result := new(PingResult)
+	err = resp.DecodeOutput(result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Arguments of user_add.
+type UserAddArgs struct {
+	// User login.
+	Login string
+}
+
+// Options of user_add.
+type UserAddOptions struct {
+	// First name.
+	First string `ipa:"givenname"`
+	// Last name.
+	Last string `ipa:"sn"`
+	// Full name.
+	Cn *string `ipa:"cn"`
+	// Display name.
+	Displayname *string `ipa:"displayname"`
+	// Home directory.
+	Homedir *string `ipa:"homedirectory"`
+	// Login shell.
+	Shell *string `ipa:"loginshell"`
+	// Kerberos principal expiration.
+	PrincipalExpiration *time.Time `ipa:"krbprincipalexpiration"`
+	// Email address.
+	Email []string `ipa:"mail,omitempty"`
+	// Prompt to set the user password.
+	Password *string `ipa:"userpassword"`
+	// User ID Number (system will assign one if not provided).
+	UID *int `ipa:"uidnumber"`
+	// Group ID Number.
+	Gidnumber *int `ipa:"gidnumber"`
+	// Telephone Number.
+	Phone []string `ipa:"telephonenumber,omitempty"`
+	// Job Title.
+	Title *string `ipa:"title"`
+	// SSH public key.
+	Sshpubkey []string `ipa:"ipasshpubkey,omitempty"`
+	// Base-64 encoded user certificate.
+	Certificate [][]byte `ipa:"usercertificate,omitempty"`
+	// Account disabled.
+	Disabled *bool `ipa:"nsaccountlock"`
+	// Don't create user private group.
+	Noprivate *bool `ipa:"noprivate"`
+	// Suppress processing of membership attributes.
+	NoMembers *bool `ipa:"no_members"`
+	// Retrieve and print all attributes from the server. Affects command output.
+	All *bool `ipa:"all"`
+	// Print entries as stored on the server. Only affects output format.
+	Raw *bool `ipa:"raw"`
+}
+
+// Result of user_add.
+type UserAddResult struct {
+	// User-friendly description of action performed.
+	Summary string `ipa:"summary"`
+	Result  User   `ipa:"result"`
+	// The primary_key value of the entry, e.g. 'jdoe' for a user.
+	Value string `ipa:"value"`
+}
+
+// Add a new user.
+func (c *Client) UserAdd(ctx context.Context, args UserAddArgs, options UserAddOptions) (*UserAddResult, error) {
+	params := []interface{}{args.Login}
+	resp, err := c.DoContext(ctx, NewRequest("user_add", params, options))
+	if err != nil {
+		return nil, err
+	}
+	result := new(UserAddResult)
+	err = resp.DecodeOutput(result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Arguments of user_del.
+type UserDelArgs struct {
+	// User login.
+	Login []string
+}
+
+// Options of user_del.
+type UserDelOptions struct {
+	// Continuous mode: Don't stop on errors.
+	Continue *bool `ipa:"continue"`
+	// Delete a user, keeping the entry available for future use.
+	Preserve *bool `ipa:"preserve"`
+}
+
+// Result of user_del.
+type UserDelResult struct {
+	// User-friendly description of action performed.
+	Summary string `ipa:"summary"`
+	// List of deletions that failed.
+	Result map[string]interface{} `ipa:"result"`
+	// The primary_key value of the entry, e.g. 'jdoe' for a user.
+	Value []string `ipa:"value"`
+}
+
+// Delete a user.
+func (c *Client) UserDel(ctx context.Context, args UserDelArgs, options UserDelOptions) (*UserDelResult, error) {
+	params := []interface{}{args.Login}
+	resp, err := c.DoContext(ctx, NewRequest("user_del", params, options))
+	if err != nil {
+		return nil, err
+	}
+	result := new(UserDelResult)
+	err = resp.DecodeOutput(result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Arguments of user_disable.
+type UserDisableArgs struct {
+	// User login.
+	Login string
+}
+
+// Options of user_disable.
+type UserDisableOptions struct {
+}
+
+// Result of user_disable.
+type UserDisableResult struct {
+	// User-friendly description of action performed.
+	Summary string `ipa:"summary"`
+	Result  bool   `ipa:"result"`
+	// The primary_key value of the entry, e.g. 'jdoe' for a user.
+	Value string `ipa:"value"`
+}
+
+// Disable a user account.
+func (c *Client) UserDisable(ctx context.Context, args UserDisableArgs, options UserDisableOptions) (*UserDisableResult, error) {
+	params := []interface{}{args.Login}
+	resp, err := c.DoContext(ctx, NewRequest("user_disable", params, options))
+	if err != nil {
+		return nil, err
+	}
+	result := new(UserDisableResult)
+	err = resp.DecodeOutput(result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Arguments of user_enable.
+type UserEnableArgs struct {
+	// User login.
+	Login string
+}
+
+// Options of user_enable.
+type UserEnableOptions struct {
+}
+
+// Result of user_enable.
+type UserEnableResult struct {
+	// User-friendly description of action performed.
+	Summary string `ipa:"summary"`
+	Result  bool   `ipa:"result"`
+	// The primary_key value of the entry, e.g. 'jdoe' for a user.
+	Value string `ipa:"value"`
+}
+
+// Enable a user account.
+func (c *Client) UserEnable(ctx context.Context, args UserEnableArgs, options UserEnableOptions) (*UserEnableResult, error) {
+	params := []interface{}{args.Login}
+	resp, err := c.DoContext(ctx, NewRequest("user_enable", params, options))
+	if err != nil {
+		return nil, err
+	}
+	result := new(UserEnableResult)
+	err = resp.DecodeOutput(result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Arguments of user_find.
+type UserFindArgs struct {
+	// A string searched in all relevant object attributes.
+	Criteria *string
+}
+
+// Options of user_find.
+type UserFindOptions struct {
+	// User login.
+	Login *string `ipa:"uid"`
+	// First name.
+	First *string `ipa:"givenname"`
+	// Last name.
+	Last *string `ipa:"sn"`
+	// Email address.
+	Email []string `ipa:"mail,omitempty"`
+	// User ID Number (system will assign one if not provided).
+	UID *int `ipa:"uidnumber"`
+	// Time limit of search in seconds (0 is unlimited).
+	Timelimit *int `ipa:"timelimit"`
+	// Maximum number of entries returned (0 is unlimited).
+	Sizelimit *int `ipa:"sizelimit"`
+	// Display user record for current Kerberos principal.
+	Whoami *bool `ipa:"whoami"`
+	// Search for users with these member of groups.
+	InGroups []string `ipa:"in_group,omitempty"`
+	// Results should contain primary key attribute only ("login").
+	PkeyOnly *bool `ipa:"pkey_only"`
+	// Suppress processing of membership attributes.
+	NoMembers *bool `ipa:"no_members"`
+	// Retrieve and print all attributes from the server. Affects command output.
+	All *bool `ipa:"all"`
+	// Print entries as stored on the server. Only affects output format.
+	Raw *bool `ipa:"raw"`
+}
+
+// Result of user_find.
+type UserFindResult struct {
+	// User-friendly description of action performed.
+	Summary string `ipa:"summary"`
+	Result  []User `ipa:"result"`
+	// Number of entries returned.
+	Count int `ipa:"count"`
+	// True if not all results were returned.
+	Truncated bool `ipa:"truncated"`
+}
+
+// Search for users.
+func (c *Client) UserFind(ctx context.Context, args UserFindArgs, options UserFindOptions) (*UserFindResult, error) {
+	params := []interface{}{}
+	if args.Criteria != nil {
+		params = append(params, *args.Criteria)
+	}
+	resp, err := c.DoContext(ctx, NewRequest("user_find", params, options))
+	if err != nil {
+		return nil, err
+	}
+	result := new(UserFindResult)
+	err = resp.DecodeOutput(result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Arguments of user_mod.
+type UserModArgs struct {
+	// User login.
+	Login string
+}
+
+// Options of user_mod.
+type UserModOptions struct {
+	// First name.
+	First *string `ipa:"givenname"`
+	// Last name.
+	Last *string `ipa:"sn"`
+	// Full name.
+	Cn *string `ipa:"cn"`
+	// Display name.
+	Displayname *string `ipa:"displayname"`
+	// Home directory.
+	Homedir *string `ipa:"homedirectory"`
+	// Login shell.
+	Shell *string `ipa:"loginshell"`
+	// Kerberos principal expiration.
+	PrincipalExpiration *time.Time `ipa:"krbprincipalexpiration"`
+	// Email address.
+	Email []string `ipa:"mail,omitempty"`
+	// Telephone Number.
+	Phone []string `ipa:"telephonenumber,omitempty"`
+	// Job Title.
+	Title *string `ipa:"title"`
+	// SSH public key.
+	Sshpubkey []string `ipa:"ipasshpubkey,omitempty"`
+	// Account disabled.
+	Disabled *bool `ipa:"nsaccountlock"`
+	// Set an attribute to a name/value pair. Format is attr=value.
+	Setattr []string `ipa:"setattr,omitempty"`
+	// Add an attribute/value pair. Format is attr=value.
+	Addattr []string `ipa:"addattr,omitempty"`
+	// Delete an attribute/value pair.
+	Delattr []string `ipa:"delattr,omitempty"`
+	// Rename the user object.
+	Rename *string `ipa:"rename"`
+	// Suppress processing of membership attributes.
+	NoMembers *bool `ipa:"no_members"`
+	// Retrieve and print all attributes from the server. Affects command output.
+	All *bool `ipa:"all"`
+	// Print entries as stored on the server. Only affects output format.
+	Raw *bool `ipa:"raw"`
+}
+
+// Result of user_mod.
+type UserModResult struct {
+	// User-friendly description of action performed.
+	Summary string `ipa:"summary"`
+	Result  User   `ipa:"result"`
+	// The primary_key value of the entry, e.g. 'jdoe' for a user.
+	Value string `ipa:"value"`
+}
+
+// Modify a user.
+func (c *Client) UserMod(ctx context.Context, args UserModArgs, options UserModOptions) (*UserModResult, error) {
+	params := []interface{}{args.Login}
+	resp, err := c.DoContext(ctx, NewRequest("user_mod", params, options))
+	if err != nil {
+		return nil, err
+	}
+	result := new(UserModResult)
+	err = resp.DecodeOutput(result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Arguments of user_show.
+type UserShowArgs struct {
+	// User login.
+	Login string
+}
+
+// Options of user_show.
+type UserShowOptions struct {
+	// file to store certificate in.
+	Out *string `ipa:"out"`
+	// Suppress processing of membership attributes.
+	NoMembers *bool `ipa:"no_members"`
+	// Retrieve and print all attributes from the server. Affects command output.
+	All *bool `ipa:"all"`
+	// Print entries as stored on the server. Only affects output format.
+	Raw *bool `ipa:"raw"`
+}
+
+// Result of user_show.
+type UserShowResult struct {
+	// User-friendly description of action performed.
+	Summary string `ipa:"summary"`
+	Result  User   `ipa:"result"`
+	// The primary_key value of the entry, e.g. 'jdoe' for a user.
+	Value string `ipa:"value"`
+}
+
+// Display information about a user.
+func (c *Client) UserShow(ctx context.Context, args UserShowArgs, options UserShowOptions) (*UserShowResult, error) {
+	params := []interface{}{args.Login}
+	resp, err := c.DoContext(ctx, NewRequest("user_show", params, options))
+	if err != nil {
+		return nil, err
+	}
+	result := new(UserShowResult)
+	err = resp.DecodeOutput(result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,91 @@
+package freeipa
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Confirm the generated command methods send typed parameters and decode typed results.
+func TestGeneratedCommands(t *testing.T) {
+	var sent *Request
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ipa/session/login_password", handleLogin)
+	mux.HandleFunc("/ipa/session/json", func(w http.ResponseWriter, req *http.Request) {
+		// Capture the request sent.
+		body, _ := io.ReadAll(req.Body)
+		sent = new(Request)
+		json.Unmarshal(body, sent)
+		if sent.Method == "user_del" {
+			fmt.Fprintf(w, `{"result": {"summary": "Deleted user \"a,b\"", "result": {"failed": []}, "value": ["a", "b"]}, "version": "4.9.8", "error": null, "id": null, "principal": "test@EXAMPLE.COM"}`)
+			return
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		handleJSON(w, req)
+	})
+	srv := httptest.NewTLSServer(mux)
+	defer srv.Close()
+
+	client, err := NewClient(
+		strings.TrimPrefix(srv.URL, "https://"),
+		WithTransport(srv.Client().Transport.(*http.Transport)),
+		WithAuthenticator(&PasswordAuthenticator{User: "test", Password: "testpassword"}),
+		WithAPIVersion("2.245"),
+	)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	ctx := context.Background()
+
+	// Add a user, confirming unset options are left out.
+	added, err := client.UserAdd(ctx, UserAddArgs{Login: "username"}, UserAddOptions{
+		First: "FreeIPA",
+		Last:  "Test",
+		Email: []string{"username@example.com"},
+		Shell: Ptr("/bin/bash"),
+	})
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	args := sent.Params[0].([]interface{})
+	options := sent.Params[1].(map[string]interface{})
+	if sent.Method != "user_add" || len(args) != 1 || args[0] != "username" {
+		t.Errorf("unexpected request: %v", sent)
+	}
+	if options["givenname"] != "FreeIPA" || options["loginshell"] != "/bin/bash" || options["version"] != "2.245" {
+		t.Errorf("unexpected options: %v", options)
+	}
+	if _, ok := options["title"]; ok {
+		t.Errorf("expected unset options to be left out: %v", options)
+	}
+	if added.Value != "username" || added.Result.Login != "username" || added.Result.Shell != "/bin/bash" || added.Result.PasswordExpiration.Year() != 2023 {
+		t.Errorf("unexpected result: %+v", added)
+	}
+
+	// Find users, confirming optional arguments are left out.
+	found, err := client.UserFind(ctx, UserFindArgs{}, UserFindOptions{})
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	if len(sent.Params[0].([]interface{})) != 0 {
+		t.Errorf("unexpected arguments: %v", sent.Params[0])
+	}
+	if found.Count != 2 || len(found.Result) != 2 || found.Result[1].Login != "johnny.bravo" {
+		t.Errorf("unexpected result: %+v", found)
+	}
+
+	// Delete users, which has a list value.
+	deleted, err := client.UserDel(ctx, UserDelArgs{Login: []string{"a", "b"}}, UserDelOptions{})
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	if len(deleted.Value) != 2 || deleted.Value[1] != "b" {
+		t.Errorf("unexpected result: %+v", deleted)
+	}
+}
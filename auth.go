@@ -0,0 +1,165 @@
+package freeipa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Authenticates a session with FreeIPA. The http client's cookie jar stores the session cookie on success.
+// The base URL is the FreeIPA API location, such as https://ipa.example.com/ipa.
+type Authenticator interface {
+	Login(ctx context.Context, client *http.Client, baseURL string) error
+}
+
+// Adapter to allow a function to be used as an authenticator.
+type AuthenticatorFunc func(ctx context.Context, client *http.Client, baseURL string) error
+
+// Call the function to login.
+func (f AuthenticatorFunc) Login(ctx context.Context, client *http.Client, baseURL string) error {
+	return f(ctx, client, baseURL)
+}
+
+// Authenticators which need to change the transport, such as to add a client certificate.
+type transportConfigurer interface {
+	configureTransport(transport *http.Transport) http.RoundTripper
+}
+
+// Authenticators which know the identity they login as, so stored sessions are kept apart for each identity.
+type identifier interface {
+	identity() string
+}
+
+// Authenticate using standard username/password.
+type PasswordAuthenticator struct {
+	User     string
+	Password string
+
+	// Called when the password has expired to obtain a new password.
+	// The password is then changed and login is retried with the new password, which is used for later logins
+	// in place of Password. The authenticator may be shared by clients, which use the changed password.
+	OnPasswordExpired func(ctx context.Context, user string) (string, error)
+
+	// Called on every login to obtain the current one-time password for users that require two factor authentication.
+	// The token is sent appended to the password. When the password has expired, it is called again for the password
+	// change and again for the login which follows, as the server does not accept a token twice.
+	OTPProvider func() (string, error)
+
+	// Password set by changing an expired password, guarded by mu as clients sharing the authenticator login separately.
+	mu      sync.Mutex
+	changed string
+}
+
+// Login using the password, changing it first if it expired and a new password can be obtained.
+func (a *PasswordAuthenticator) Login(ctx context.Context, client *http.Client, baseURL string) error {
+	// Login using the password.
+	otp, err := a.otp()
+	if err != nil {
+		return err
+	}
+	password := a.password()
+	err = a.login(ctx, client, baseURL, password, otp)
+
+	// If the password expired and we can obtain a new one, change it and login again.
+	var authErr *AuthError
+	if a.OnPasswordExpired != nil && errors.As(err, &authErr) && authErr.Reason == AuthReasonPasswordExpired {
+		newPassword, err := a.OnPasswordExpired(ctx, a.User)
+		if err != nil {
+			return fmt.Errorf("error obtaining new password: %w", err)
+		}
+
+		// Each step needs a new one-time password, as a token is only accepted once.
+		otp, err = a.otp()
+		if err != nil {
+			return err
+		}
+		err = changePassword(ctx, client, baseURL, a.User, password, newPassword, otp)
+		if err != nil {
+			return fmt.Errorf("error changing expired password: %w", err)
+		}
+		a.setPassword(newPassword)
+		otp, err = a.otp()
+		if err != nil {
+			return err
+		}
+		return a.login(ctx, client, baseURL, newPassword, otp)
+	}
+	return err
+}
+
+// Get the user logged in as.
+func (a *PasswordAuthenticator) identity() string {
+	return a.User
+}
+
+// Get the current password, which is the changed password if an expired password was changed.
+func (a *PasswordAuthenticator) password() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.changed != "" {
+		return a.changed
+	}
+	return a.Password
+}
+
+// Keep the password changed from an expired password for later logins.
+func (a *PasswordAuthenticator) setPassword(password string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.changed = password
+}
+
+// Get the current one-time password if an OTP provider is configured.
+func (a *PasswordAuthenticator) otp() (string, error) {
+	if a.OTPProvider == nil {
+		return "", nil
+	}
+	otp, err := a.OTPProvider()
+	if err != nil {
+		return "", fmt.Errorf("error obtaining one-time password: %w", err)
+	}
+	return otp, nil
+}
+
+// Login using the username/password form, with the one-time password if provided.
+func (a *PasswordAuthenticator) login(ctx context.Context, client *http.Client, baseURL, password, otp string) error {
+	// Setup form data with credentials, FreeIPA expects the one-time password to be appended to the password.
+	data := url.Values{
+		"user":     []string{a.User},
+		"password": []string{password + otp},
+	}
+	// Setup request for authenticate.
+	req, err := http.NewRequestWithContext(ctx, "POST", baseURL+"/session/login_password", strings.NewReader(data.Encode()))
+	if err != nil {
+		return fmt.Errorf("error building login request: %s", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	// Authenticate using standard credentials with the http client.
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	// If an error occurs, provide details if possible on why.
+	if res.StatusCode != http.StatusOK {
+		if res.StatusCode == http.StatusUnauthorized {
+			err := unauthorizedHTTPError(res)
+			// Note that a one-time password was sent, as FreeIPA does not distinguish an invalid token from an invalid password.
+			var authErr *AuthError
+			if errors.As(err, &authErr) {
+				authErr.OTP = otp != ""
+			}
+			return err
+		}
+		return unexpectedHTTPError(res)
+	}
+
+	// Successful authentication.
+	return nil
+}
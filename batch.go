@@ -0,0 +1,157 @@
+package freeipa
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Default number of requests sent in each batch call.
+const DefaultBatchSize = 100
+
+// Requests to send together using the batch command.
+type Batch struct {
+	Requests []*Request
+	// Maximum number of requests sent in each batch call, DefaultBatchSize is used if not set.
+	// Larger batches are split into multiple calls to stay within server limits.
+	ChunkSize int
+}
+
+// Result of a request in a batch, with either the response or the error for the request.
+type BatchResult struct {
+	Request  *Request
+	Response *Response
+	Err      error
+}
+
+// Make a new batch with the provided requests.
+func NewBatch(requests ...*Request) *Batch {
+	return &Batch{
+		Requests: requests,
+	}
+}
+
+// Add requests to the batch.
+func (b *Batch) Add(requests ...*Request) {
+	b.Requests = append(b.Requests, requests...)
+}
+
+// Result of a command in a batch response, which has error details in place of a result on failure.
+type batchItem struct {
+	Error     *string                `json:"error"`
+	ErrorCode int                    `json:"error_code"`
+	ErrorName string                 `json:"error_name"`
+	ErrorKw   map[string]interface{} `json:"error_kw"`
+	Result    Result                 `json:"-"`
+}
+
+// Get the error of a failed command, or nil if it succeeded.
+func (item *batchItem) err() error {
+	if item.Error == nil {
+		return nil
+	}
+	return &Error{
+		Code:       item.ErrorCode,
+		Name:       item.ErrorName,
+		Message:    *item.Error,
+		Data:       item.ErrorKw,
+		StatusCode: http.StatusOK,
+	}
+}
+
+// Have the client perform the batch, with a result for each request in the order they were added.
+// A failure of one request does not affect the others. If a batch call fails as a whole, each request
+// in that call has the error as its result, and the first such error is returned along with all results.
+func (c *Client) DoBatch(ctx context.Context, batch *Batch) ([]BatchResult, error) {
+	chunkSize := batch.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = DefaultBatchSize
+	}
+
+	results := make([]BatchResult, len(batch.Requests))
+	var batchErr error
+	for start := 0; start < len(batch.Requests); start += chunkSize {
+		end := start + chunkSize
+		if end > len(batch.Requests) {
+			end = len(batch.Requests)
+		}
+
+		// Perform the chunk, recording the error against each request if the call failed.
+		err := c.doBatchChunk(ctx, batch.Requests[start:end], results[start:end])
+		if err != nil {
+			for i := start; i < end; i++ {
+				// Keep errors from requests which could not be encoded.
+				if results[i].Err == nil {
+					results[i] = BatchResult{Request: batch.Requests[i], Err: err}
+				}
+			}
+			if batchErr == nil {
+				batchErr = err
+			}
+		}
+	}
+	return results, batchErr
+}
+
+// Send the requests in a single batch call, filling in the result for each request.
+func (c *Client) doBatchChunk(ctx context.Context, requests []*Request, results []BatchResult) error {
+	// Each request is sent as a method and params pair, the same as a standalone request.
+	// Requests which cannot be encoded or fail validation are not sent, and have the error as their result.
+	var commands []interface{}
+	var sent []int
+	for i, req := range requests {
+		results[i].Request = req
+		command, err := c.prepareRequest(req)
+		if err == nil && c.validate {
+			err = c.validateRequest(ctx, command)
+		}
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		commands = append(commands, command)
+		sent = append(sent, i)
+	}
+	if len(commands) == 0 {
+		return nil
+	}
+	resp, err := c.DoContext(ctx, NewRequest("batch", commands, map[string]interface{}{}))
+	if err != nil {
+		return err
+	}
+
+	// There should be a result for each request sent.
+	if len(resp.Result.Results) != len(sent) {
+		return fmt.Errorf("batch returned %d results for %d requests", len(resp.Result.Results), len(sent))
+	}
+
+	// Parse each result.
+	for j, raw := range resp.Result.Results {
+		i := sent[j]
+		item := new(batchItem)
+		err := json.Unmarshal(raw, item)
+		if err == nil && item.Error == nil {
+			err = json.Unmarshal(raw, &item.Result)
+		}
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+
+		// Failed requests provide the error in place of the result.
+		if item.Error != nil {
+			results[i].Err = item.err()
+			continue
+		}
+
+		result := item.Result
+		results[i].Response = &Response{
+			Result:    &result,
+			Version:   resp.Version,
+			Principal: resp.Principal,
+			Server:    resp.Server,
+		}
+	}
+	return nil
+}
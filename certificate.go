@@ -0,0 +1,144 @@
+package freeipa
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Options for connecting with a client certificate.
+type CertificateConnectOptions struct {
+	// Transport to add the client certificate to, a default transport is used if not provided.
+	// The transport is cloned, so the provided transport is not modified.
+	Transport *http.Transport
+	// User to login as, only needed if the certificate is mapped to more than one user.
+	User string
+}
+
+// Create a new client using client certificate authentication.
+func ConnectWithCertificate(host string, cert tls.Certificate, options *CertificateConnectOptions) (*Client, error) {
+	return ConnectWithCertificateContext(context.Background(), host, cert, options)
+}
+
+// Create a new client using client certificate authentication, with the login bound to the context.
+func ConnectWithCertificateContext(ctx context.Context, host string, cert tls.Certificate, options *CertificateConnectOptions) (*Client, error) {
+	// Default to no extra options.
+	if options == nil {
+		options = &CertificateConnectOptions{}
+	}
+
+	// Setup certificate authentication, which adds the certificate to the transport.
+	auth := &CertificateAuthenticator{
+		Certificate: cert,
+		User:        options.User,
+	}
+	return NewClientContext(ctx, host, WithTransport(options.Transport), WithAuthenticator(auth))
+}
+
+// Authenticate using a client certificate. The certificate is added to the client's transport.
+type CertificateAuthenticator struct {
+	Certificate tls.Certificate
+	// User to login as, only needed if the certificate is mapped to more than one user.
+	User string
+}
+
+// Path of the certificate login location, relative to the base URL.
+const certificateLoginPath = "/session/login_x509"
+
+// Transport which sends certificate logins with a separate transport presenting the client certificate.
+type certificateTransport struct {
+	login *http.Transport
+	next  http.RoundTripper
+}
+
+// Send certificate logins with the login transport, and other requests with the next transport.
+func (t *certificateTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if strings.HasSuffix(req.URL.Path, certificateLoginPath) {
+		return t.login.RoundTrip(req)
+	}
+	return t.next.RoundTrip(req)
+}
+
+// Close idle connections of both transports.
+func (t *certificateTransport) CloseIdleConnections() {
+	t.login.CloseIdleConnections()
+	if closer, ok := t.next.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
+// Make a transport which presents the client certificate on the login location only.
+func (a *CertificateAuthenticator) configureTransport(transport *http.Transport) http.RoundTripper {
+	if transport == nil {
+		transport = http.DefaultTransport.(*http.Transport)
+	}
+	login := transport.Clone()
+	if login.TLSClientConfig == nil {
+		login.TLSClientConfig = &tls.Config{}
+	}
+	login.TLSClientConfig.Certificates = append(login.TLSClientConfig.Certificates, a.Certificate)
+
+	// FreeIPA only requests the certificate on the login location, which requires renegotiation.
+	// Post-handshake authentication in TLS 1.3 is not supported by Go, so TLS 1.2 is required for logins.
+	// Other requests use the transport as provided.
+	login.TLSClientConfig.Renegotiation = tls.RenegotiateOnceAsClient
+	if login.TLSClientConfig.MaxVersion == 0 {
+		login.TLSClientConfig.MaxVersion = tls.VersionTLS12
+	}
+	return &certificateTransport{
+		login: login,
+		next:  transport,
+	}
+}
+
+// Get the user logged in as, or the fingerprint of the certificate if no user was specified.
+func (a *CertificateAuthenticator) identity() string {
+	if a.User != "" {
+		return a.User
+	}
+	if len(a.Certificate.Certificate) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256(a.Certificate.Certificate[0])
+	return hex.EncodeToString(sum[:])
+}
+
+// Login using the client certificate presented in the TLS handshake.
+func (a *CertificateAuthenticator) Login(ctx context.Context, client *http.Client, baseURL string) error {
+	// Setup form data with the user if one was specified.
+	data := url.Values{}
+	if a.User != "" {
+		data.Set("username", a.User)
+	}
+
+	// Setup request for authenticate.
+	req, err := http.NewRequestWithContext(ctx, "POST", baseURL+certificateLoginPath, strings.NewReader(data.Encode()))
+	if err != nil {
+		return fmt.Errorf("error building login request: %s", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Referer", baseURL)
+
+	// Authenticate using the certificate presented in the TLS handshake.
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	// If an error occurs, provide details if possible on why.
+	if res.StatusCode != http.StatusOK {
+		if res.StatusCode == http.StatusUnauthorized {
+			return unauthorizedHTTPError(res)
+		}
+		return unexpectedHTTPError(res)
+	}
+
+	// Successful authentication.
+	return nil
+}
@@ -1,178 +1,211 @@
 package freeipa
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
-	"net/http/cookiejar"
-	"net/url"
-
-	krb5client "github.com/jcmturner/gokrb5/v8/client"
-	krb5config "github.com/jcmturner/gokrb5/v8/config"
-	"github.com/jcmturner/gokrb5/v8/keytab"
-	"github.com/jcmturner/gokrb5/v8/spnego"
+	"sync"
+	"time"
 )
 
 // The base object for connections to FreeIPA API.
+// A client is safe for use by multiple goroutines, which share its sessions with each server.
 type Client struct {
-	uriBase  string
-	client   *http.Client
-	user     string
-	password string
-	krb5     *krb5client.Client
+	servers          []*server
+	policy           ServerPolicy
+	next             uint32
+	client           *http.Client
+	auth             Authenticator
+	loginSem         chan struct{}
+	store            SessionStore
+	apiVersion       string
+	apiVersionSource APIVersionSource
+	serverVersion    string
+	validate         bool
+	retry            RetryPolicy
+	roundTrip        RoundTrip
+
+	// Session tracking and background keep-alive, stopped by Close.
+	sessionLifetime time.Duration
+	closing         chan struct{}
+	closeOnce       sync.Once
+	keepAliveDone   chan struct{}
+
+	// Schemas fetched from the servers, keyed by fingerprint, and the fetch in progress which is closed when done.
+	schemaMu          sync.Mutex
+	schemas           map[string]*Schema
+	schemaFingerprint string
+	schemaExpires     time.Time
+	schemaFetch       chan struct{}
 }
 
-// Internal function with common init code for each connection type, mainly sets http.Client and uriBase.
-func (c *Client) init(host string, transport *http.Transport) error {
-	// Create a cookie jar to store FreeIPA session cookies.
-	jar, err := cookiejar.New(&cookiejar.Options{})
+// Internal function with common init code for each connection type, mainly sets http.Client and the servers.
+func (c *Client) init(host string, options *clientOptions) error {
+	// Setup the http client from the options.
+	client, err := options.httpClientFor(c.auth)
 	if err != nil {
 		return err
 	}
-	// Setup client using provided transport configurations and the cookie jar.
-	c.client = &http.Client{
-		Transport: transport,
-		Jar:       jar,
+	c.client = client
+	c.loginSem = make(chan struct{}, 1)
+	c.store = options.store
+	c.closing = make(chan struct{})
+
+	// Send requests through the middleware.
+	c.roundTrip = chainMiddleware(c.doPrepared, options.middleware)
+
+	// Track session expiry from the session cookies set by each server.
+	c.sessionLifetime = options.lifetime
+	c.client.Transport = &sessionTransport{
+		client: c,
+		next:   c.client.Transport,
 	}
 
-	// Set uriBase using the provided host and test to verify a valid URL is produced.
-	c.uriBase = fmt.Sprintf("https://%s/ipa", host)
-	_, err = url.Parse(c.uriBase)
-	if err != nil {
-		return err
+	// Setup the servers, with the provided host first.
+	hosts := options.servers
+	if host != "" {
+		hosts = append([]string{host}, hosts...)
+	}
+	if len(hosts) == 0 {
+		return errors.New("no servers provided")
+	}
+	for _, h := range hosts {
+		srv, err := newServer(h, options.basePath)
+		if err != nil {
+			return err
+		}
+		c.servers = append(c.servers, srv)
 	}
+	c.policy = options.policy
 	return nil
 }
 
-// Make a new client and login using standard username/password.
-func Connect(host string, transport *http.Transport, user, password string) (*Client, error) {
-	// Make the client config and save credentials.
-	client := &Client{
-		user:     user,
-		password: password,
-	}
+// Make a new client and login using the configured authenticator.
+func NewClient(host string, opts ...Option) (*Client, error) {
+	return NewClientContext(context.Background(), host, opts...)
+}
 
-	// Initialize common configurations.
-	err := client.init(host, transport)
+// Make a new client and login using the configured authenticator, with the login bound to the context.
+func NewClientContext(ctx context.Context, host string, opts ...Option) (*Client, error) {
+	// Apply the options.
+	options, err := newClientOptions(opts)
 	if err != nil {
 		return nil, err
 	}
 
-	// Login using credentials.
-	err = client.login()
-	if err != nil {
-		return nil, fmt.Errorf("login failed: %s", err)
+	// An authenticator is required to login.
+	if options.auth == nil {
+		return nil, errors.New("an authenticator is required")
 	}
 
-	return client, nil
-}
-
-// Login using standard credentials.
-func (c *Client) login() error {
-	// If login is called, but kerberos client is configured, use kerberos login instead.
-	// This allows standard re-authentication calls to work with both kerbeos and standard authenciation.
-	if c.krb5 != nil {
-		return c.loginWithKerberos()
+	// Make the client with the authenticator.
+	client := &Client{
+		auth:       options.auth,
+		apiVersion: options.apiVersion,
+		validate:   options.validate,
+		retry:      options.retry,
 	}
 
-	// Setup form data with credentials.
-	data := url.Values{
-		"user":     []string{c.user},
-		"password": []string{c.password},
+	// Initialize common configurations.
+	err = client.init(host, options)
+	if err != nil {
+		return nil, err
 	}
-	// Authenticate using standard credentials with the http client.
-	res, e := c.client.PostForm(c.uriBase+"/session/login_password", data)
-	if e != nil {
-		return e
+
+	// Login using the authenticator, unless a stored session is still valid.
+	if !client.restoreSessions(ctx) {
+		err = client.login(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("login failed: %w", err)
+		}
 	}
 
-	// If an error occurs, provide details if possible on why.
-	if res.StatusCode != http.StatusOK {
-		if res.StatusCode == http.StatusUnauthorized {
-			return unauthorizedHTTPError(res)
+	// Use the API version the server supports, unless one was specified.
+	if client.apiVersion == "" {
+		err = client.negotiateAPIVersion(ctx)
+		if err != nil {
+			return nil, err
 		}
-		return fmt.Errorf("unexpected http status code: %d", res.StatusCode)
 	}
 
-	// Successful authentication.
-	return nil
-}
+	// Keep sessions alive in the background if enabled.
+	if options.keepAlive > 0 {
+		client.keepAliveDone = make(chan struct{})
+		go client.keepAlive(options.keepAlive)
+	}
 
-// Options for connecting to Kerberos.
-type KerberosConnectOptions struct {
-	Krb5ConfigReader io.Reader
-	KeytabReader     io.Reader
-	User             string
-	Realm            string
+	return client, nil
 }
 
-// Create a new client using Kerberos authentication.
-func ConnectWithKerberos(host string, transport *http.Transport, options *KerberosConnectOptions) (*Client, error) {
-	// Read the kerberos configuration file for server connection information.
-	krb5Config, err := krb5config.NewFromReader(options.Krb5ConfigReader)
-	if err != nil {
-		return nil, fmt.Errorf("error reading kerberos configuration: %s", err)
-	}
-
-	// Read the keytab data.
-	ktData, err := io.ReadAll(options.KeytabReader)
-	if err != nil {
-		return nil, fmt.Errorf("error reading keytab: %s", err)
-	}
+// Make a new client and login using standard username/password.
+func Connect(host string, transport *http.Transport, user, password string) (*Client, error) {
+	return ConnectContext(context.Background(), host, transport, user, password)
+}
 
-	// Parse the keytab data.
-	kt := keytab.New()
-	err = kt.Unmarshal(ktData)
-	if err != nil {
-		return nil, fmt.Errorf("error parsing keytab: %s", err)
-	}
+// Make a new client and login using standard username/password, with the login bound to the context.
+func ConnectContext(ctx context.Context, host string, transport *http.Transport, user, password string) (*Client, error) {
+	return ConnectWithOptions(ctx, host, transport, user, password, nil)
+}
 
-	// Setup kerberos client with keytab and config.
-	krb5 := krb5client.NewWithKeytab(options.User, options.Realm, kt, krb5Config)
+// Options for connecting with standard username/password.
+type ConnectOptions struct {
+	// Called when the password has expired to obtain a new password.
+	// The password is then changed and login is retried with the new password.
+	OnPasswordExpired func(ctx context.Context, user string) (string, error)
 
-	// Setup the client with kerberos's client for authentication.
-	client := &Client{
-		user: options.User,
-		krb5: krb5,
-	}
+	// Called on every login, including automatic re-login, to obtain the current one-time password
+	// for users that require two factor authentication. The token is sent appended to the password.
+	OTPProvider func() (string, error)
+}
 
-	// Initialize the common configurations.
-	err = client.init(host, transport)
-	if err != nil {
-		return nil, err
+// Make a new client and login using standard username/password with extra options.
+func ConnectWithOptions(ctx context.Context, host string, transport *http.Transport, user, password string, options *ConnectOptions) (*Client, error) {
+	// Default to no extra options.
+	if options == nil {
+		options = &ConnectOptions{}
 	}
 
-	// Login using kerberos authentication.
-	err = client.login()
-	if err != nil {
-		return nil, fmt.Errorf("login failed: %s", err)
+	// Setup password authentication with the credentials.
+	auth := &PasswordAuthenticator{
+		User:              user,
+		Password:          password,
+		OnPasswordExpired: options.OnPasswordExpired,
+		OTPProvider:       options.OTPProvider,
 	}
-	return client, nil
+	return NewClientContext(ctx, host, WithTransport(transport), WithAuthenticator(auth))
 }
 
-// Login using kerberos client. The regular login function will call this function if needed.
-func (c *Client) loginWithKerberos() error {
-	// Wrapper for authenticating with Kerberos credentials.
-	spnegoCl := spnego.NewClient(c.krb5, c.client, "")
-
-	// Setup request for authenticate.
-	req, err := http.NewRequest("POST", c.uriBase+"/session/login_kerberos", nil)
-	if err != nil {
-		return fmt.Errorf("error building login request: %s", err)
-	}
-	req.Header.Add("Referer", c.uriBase)
+// Login to the first available server using the configured authenticator.
+func (c *Client) login(ctx context.Context) error {
+	return c.eachServer(ctx, true, func(ctx context.Context, srv *server) error {
+		_, seq := srv.session()
+		return c.loginServer(ctx, srv, seq)
+	})
+}
 
-	// Perform authenticate using Kerberos.
-	res, err := spnegoCl.Do(req)
-	if err != nil {
-		return fmt.Errorf("error logging in using Kerberos: %s", err)
+// Login to the server using the configured authenticator. Sessions are per server, as cookies are host scoped.
+// Only one login runs at a time. If a login was attempted since the sequence number was observed, its result
+// is used instead of logging in again, so goroutines which find the session expired together only login once.
+// A login ended by its caller's context is attempted again under this context.
+func (c *Client) loginServer(ctx context.Context, srv *server, seq uint64) error {
+	// Wait for any other login to finish.
+	select {
+	case c.loginSem <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-c.loginSem }()
+
+	// Share the result of a login which happened while waiting, unless it was stopped by the other caller's context.
+	if ok, err := srv.loginSince(seq); ok && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) {
+		return err
 	}
 
-	// If an error occurs, return it.
-	if res.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected http status code: %d", res.StatusCode)
+	err := c.auth.Login(ctx, c.client, srv.uriBase)
+	srv.recordLogin(err)
+	if err == nil {
+		c.saveSession(ctx, srv)
 	}
-
-	// Successful authentication.
-	return nil
+	return err
 }
@@ -1,15 +1,27 @@
 package freeipa
 
 import (
+	"bytes"
+	"context"
 	"crypto/tls"
 	"encoding/json"
+	"encoding/pem"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/jcmturner/gokrb5/v8/iana/etypeID"
+	"github.com/jcmturner/gokrb5/v8/keytab"
 )
 
 // Unused port for testing.
@@ -23,7 +35,7 @@ func handleLogin(w http.ResponseWriter, req *http.Request) {
 	// Check username/password equals test credentials.
 	user := req.Form.Get("user")
 	password := req.Form.Get("password")
-	if user == "test" && password == "testpassword" {
+	if (user == "test" && password == "testpassword") || (user == "expired" && password == "newpassword") || (user == "otp" && password == "testpassword123456") || (user == "otpexpired" && password == "newpassword333333") {
 		// Successful login send session cookie.
 		cookie := http.Cookie{}
 		cookie.Name = "ipa_session"
@@ -34,6 +46,10 @@ func handleLogin(w http.ResponseWriter, req *http.Request) {
 		cookie.Path = "/ipa"
 		http.SetCookie(w, &cookie)
 		w.Header().Set("IPASESSION", "correct-session-secret")
+	} else if (user == "expired" && password == "oldpassword") || (user == "otpexpired" && password == "oldpassword111111") {
+		// Expired password, send rejection.
+		w.Header().Set("X-IPA-Rejection-Reason", "password-expired")
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
 	} else {
 		// Invalid login, send rejection.
 		w.Header().Set("X-IPA-Rejection-Reason", "invalid-password")
@@ -53,6 +69,23 @@ func handleLogin(w http.ResponseWriter, req *http.Request) {
 	}
 }
 
+// Test password change handler.
+func handleChangePassword(w http.ResponseWriter, req *http.Request) {
+	req.ParseForm()
+
+	// Only the expired test users may change their password, and only to a password meeting policy.
+	user := req.Form.Get("user")
+	if (user != "expired" && user != "otpexpired") || req.Form.Get("old_password") != "oldpassword" || (user == "otpexpired" && req.Form.Get("otp") != "222222") {
+		w.Header().Set("X-IPA-Pwchange-Result", "invalid-password")
+	} else if len(req.Form.Get("new_password")) < 8 {
+		w.Header().Set("X-IPA-Pwchange-Result", "policy-error")
+		w.Header().Set("X-IPA-Pwchange-Policy-Error", "Constraint violation: Password is too short")
+	} else {
+		w.Header().Set("X-IPA-Pwchange-Result", "ok")
+	}
+	fmt.Fprintf(w, "Password change result\n")
+}
+
 // General invalid json error response for testing error handling.
 func sendInvalidJSON(w http.ResponseWriter) {
 	f, err := os.Open("test/invalid_json.json")
@@ -84,8 +117,39 @@ func handleJSON(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	// For testing, we'll consider user_add/user_find as an accepted method, all others will error.
-	if res.Method == "user_add" {
+	// For testing, we'll consider ping/batch/schema/user_add/user_find as an accepted method, all others will error.
+	if res.Method == "ping" {
+		// Send the server and API versions.
+		fmt.Fprintf(w, `{"result": {"summary": "IPA server version 4.9.8. API version 2.245", "messages": []}, "version": "4.9.8", "error": null, "id": null, "principal": "test@EXAMPLE.COM"}`)
+	} else if res.Method == "batch" {
+		// Send a result for each command, with an error for missing users.
+		var results []string
+		for _, param := range res.Params[0].([]interface{}) {
+			command := param.(map[string]interface{})
+			uid := command["params"].([]interface{})[0].([]interface{})[0].(string)
+			if uid == "missing" {
+				results = append(results, `{"error": "missing: user not found", "error_code": 4001, "error_name": "NotFound", "error_kw": {"reason": "missing: user not found"}}`)
+			} else {
+				results = append(results, fmt.Sprintf(`{"summary": null, "result": {"uid": ["%s"]}, "value": "%s", "error": null}`, uid, uid))
+			}
+		}
+		fmt.Fprintf(w, `{"result": {"count": %d, "results": [%s]}, "version": "4.9.8", "error": null, "id": null, "principal": "test@EXAMPLE.COM"}`, len(results), strings.Join(results, ","))
+	} else if res.Method == "schema" {
+		// Send the schema fixture, unless the client already has it.
+		known, _ := res.Params[1].(map[string]interface{})["known_fingerprints"].([]interface{})
+		for _, fingerprint := range known {
+			if fingerprint == GeneratedSchemaFingerprint {
+				fmt.Fprintf(w, `{"result": null, "version": "4.9.8", "error": {"code": 4311, "name": "SchemaUpToDate", "message": "Schema is up to date (FP '%s', TTL 3600 s)", "data": {"fingerprint": "%s", "ttl": 3600}}, "id": null, "principal": "test@EXAMPLE.COM"}`, fingerprint, fingerprint)
+				return
+			}
+		}
+		f, err := os.Open("cmd/freeipa-gen/schema.json")
+		if err != nil {
+			log.Fatalln(err)
+		}
+		defer f.Close()
+		io.Copy(w, f)
+	} else if res.Method == "user_add" {
 		// Send user add response data.
 		f, err := os.Open("test/user_add_response.json")
 		if err != nil {
@@ -113,6 +177,7 @@ func TestLogin(t *testing.T) {
 	srvAddr := fmt.Sprintf("127.0.0.1:%d", httpsPort)
 	http.HandleFunc("/ipa/session/login_password", handleLogin)
 	http.HandleFunc("/ipa/session/json", handleJSON)
+	http.HandleFunc("/ipa/session/change_password", handleChangePassword)
 	go func() {
 		err := http.ListenAndServeTLS(srvAddr, "test/cert.pem", "test/key.pem", nil)
 		if err != nil {
@@ -132,6 +197,72 @@ func TestLogin(t *testing.T) {
 	if err == nil || err.Error() != "login failed: unauthorized response <invalid-password> (1201)" {
 		t.Fatalf("expected login failure")
 	}
+	if !IsAuthentication(err) || !errors.Is(err, ErrInvalidSessionPassword) {
+		t.Errorf("expected authentication error: %s", err)
+	}
+	var authErr *AuthError
+	if !errors.As(err, &authErr) || authErr.Reason != AuthReasonInvalidPassword || !strings.Contains(authErr.Body, "Password incorrect") {
+		t.Errorf("unexpected authentication error details: %#v", authErr)
+	}
+
+	// Connect with an expired password and confirm the password is changed before logging in.
+	options := &ConnectOptions{
+		OnPasswordExpired: func(ctx context.Context, user string) (string, error) {
+			return "newpassword", nil
+		},
+	}
+	expiredClient, err := ConnectWithOptions(context.Background(), srvAddr, transportConfig, "expired", "oldpassword", options)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+
+	// Confirm password policy rejections are reported.
+	var pwErr *PasswordChangeError
+	err = expiredClient.ChangePassword(context.Background(), "expired", "oldpassword", "short", "")
+	if !errors.As(err, &pwErr) || pwErr.Reason != PasswordChangeReasonPolicy || pwErr.PolicyError == "" {
+		t.Errorf("expected password policy error: %s", err)
+	}
+
+	// Connect with a one-time password appended to the password.
+	otpOptions := &ConnectOptions{
+		OTPProvider: func() (string, error) {
+			return "123456", nil
+		},
+	}
+	_, err = ConnectWithOptions(context.Background(), srvAddr, transportConfig, "otp", "testpassword", otpOptions)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+
+	// Recover an expired password with a new one-time password for the login, the password change and the login after.
+	tokens := []string{"111111", "222222", "333333"}
+	otpOptions.OTPProvider = func() (string, error) {
+		if len(tokens) == 0 {
+			return "", errors.New("no more tokens")
+		}
+		token := tokens[0]
+		tokens = tokens[1:]
+		return token, nil
+	}
+	otpOptions.OnPasswordExpired = options.OnPasswordExpired
+	_, err = ConnectWithOptions(context.Background(), srvAddr, transportConfig, "otpexpired", "oldpassword", otpOptions)
+	if err != nil {
+		t.Errorf("expected expired password with one-time password to be changed: %s", err)
+	}
+	if len(tokens) != 0 {
+		t.Errorf("expected a one-time password for each step: %v", tokens)
+	}
+	otpOptions.OnPasswordExpired = nil
+
+	// Confirm an invalid token is reported as a login with a one-time password.
+	otpOptions.OTPProvider = func() (string, error) {
+		return "654321", nil
+	}
+	_, err = ConnectWithOptions(context.Background(), srvAddr, transportConfig, "otp", "testpassword", otpOptions)
+	var otpErr *AuthError
+	if !errors.As(err, &otpErr) || !otpErr.OTP {
+		t.Errorf("expected one-time password login failure: %s", err)
+	}
 
 	// Connect using correct password to confirm valid logins are handled correctly.
 	client, err := Connect(srvAddr, transportConfig, "test", "testpassword")
@@ -139,6 +270,11 @@ func TestLogin(t *testing.T) {
 		t.Fatalf("error: %s", err)
 	}
 
+	// Confirm the API version was negotiated with the server.
+	if client.APIVersion() != "2.245" || client.ServerVersion() != "4.9.8" || client.APIVersionSource() != APIVersionNegotiated {
+		t.Errorf("unexpected versions: %s %s", client.APIVersion(), client.ServerVersion())
+	}
+
 	// Setup test user_add request.
 	params := make(map[string]interface{})
 	params["givenname"] = "FreeIPA"
@@ -199,6 +335,15 @@ func TestLogin(t *testing.T) {
 		t.Fatalf("unexpected error: %s", err)
 	}
 
+	// Confirm the error can be inspected as a FreeIPA error.
+	var ipaErr *Error
+	if !errors.As(err, &ipaErr) || ipaErr.Code != JSONErrorCode || ipaErr.StatusCode != http.StatusOK {
+		t.Errorf("unexpected error details: %#v", ipaErr)
+	}
+	if IsNotFound(err) || IsDuplicate(err) {
+		t.Errorf("unexpected error classification: %s", err)
+	}
+
 	// Test user_find.
 	params = make(map[string]interface{})
 	params["pkey_only"] = true
@@ -236,4 +381,572 @@ func TestLogin(t *testing.T) {
 	if uid != "johnny.bravo" {
 		t.Errorf("unexpected string: %s", uid)
 	}
+
+	// Confirm a canceled context stops the request before it is sent.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = client.DoContext(ctx, req)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context canceled error: %s", err)
+	}
+}
+
+// Confirm the credential cache location follows KRB5CCNAME, then the Kerberos configuration.
+func TestDefaultCCachePath(t *testing.T) {
+	t.Setenv("KRB5CCNAME", "FILE:/tmp/krb5cc_test")
+	path, err := defaultCCachePath("FILE:/tmp/krb5cc_configured")
+	if err != nil || path != "/tmp/krb5cc_test" {
+		t.Errorf("unexpected credential cache path: %s %v", path, err)
+	}
+
+	// Caches which are not files cannot be read.
+	t.Setenv("KRB5CCNAME", "KEYRING:persistent:1000")
+	_, err = defaultCCachePath("")
+	if err == nil {
+		t.Errorf("expected unsupported credential cache error")
+	}
+
+	// Without KRB5CCNAME, the default_ccache_name from the configuration is used.
+	t.Setenv("KRB5CCNAME", "")
+	t.Setenv("TMPDIR", "/var/tmp")
+	_, configured, err := loadKrb5Config(&KerberosConnectOptions{Krb5ConfigReader: strings.NewReader(`[libdefaults]
+  default_realm = EXAMPLE.COM
+  # default_ccache_name = KEYRING:persistent:%{uid}
+  default_ccache_name = FILE:%{TEMP}/krb5cc_%{uid}_app
+
+[realms]
+  EXAMPLE.COM = {
+    kdc = ipa.example.com
+    default_ccache_name = FILE:/tmp/realm
+  }
+`)})
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	path, err = defaultCCachePath(configured)
+	if err != nil || path != fmt.Sprintf("/var/tmp/krb5cc_%d_app", os.Getuid()) {
+		t.Errorf("unexpected configured credential cache path: %s %v", path, err)
+	}
+
+	// Without either, the default location for the user is used.
+	path, _ = defaultCCachePath("")
+	if path != fmt.Sprintf("/tmp/krb5cc_%d", os.Getuid()) {
+		t.Errorf("unexpected credential cache path: %s", path)
+	}
+}
+
+// Confirm Kerberos clients are made from a keytab, or a password when no keytab is provided.
+func TestKerberosCredentials(t *testing.T) {
+	const krb5Conf = `[libdefaults]
+  default_realm = EXAMPLE.COM
+
+[realms]
+  EXAMPLE.COM = {
+    kdc = ipa.example.com
+  }
+`
+
+	// Make a keytab for the user.
+	kt := keytab.New()
+	err := kt.AddEntry("test", "EXAMPLE.COM", "testpassword", time.Now(), 1, etypeID.AES256_CTS_HMAC_SHA1_96)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	ktData, err := kt.Marshal()
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	krb5, err := newKrb5Client(&KerberosConnectOptions{
+		Krb5ConfigReader: strings.NewReader(krb5Conf),
+		KeytabReader:     bytes.NewReader(ktData),
+		Password:         "ignored",
+		User:             "test",
+		Realm:            "EXAMPLE.COM",
+	})
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	if !krb5.Credentials.HasKeytab() || krb5.Credentials.HasPassword() || krb5.Credentials.UserName() != "test" {
+		t.Errorf("expected keytab credentials: %+v", krb5.Credentials)
+	}
+
+	// Without a keytab, the password is used.
+	krb5, err = newKrb5Client(&KerberosConnectOptions{
+		Krb5ConfigReader: strings.NewReader(krb5Conf),
+		Password:         "testpassword",
+		User:             "test",
+		Realm:            "EXAMPLE.COM",
+	})
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	if !krb5.Credentials.HasPassword() || krb5.Credentials.Password() != "testpassword" || krb5.Credentials.HasKeytab() ||
+		krb5.Credentials.UserName() != "test" || krb5.Credentials.Domain() != "EXAMPLE.COM" {
+		t.Errorf("expected password credentials: %+v", krb5.Credentials)
+	}
+}
+
+// Confirm logging in with a client certificate.
+func TestCertificateLogin(t *testing.T) {
+	// Login is only accepted if a client certificate was presented.
+	var loginVersion, jsonVersion uint16
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ipa/session/login_x509", func(w http.ResponseWriter, req *http.Request) {
+		if req.TLS == nil || len(req.TLS.PeerCertificates) == 0 {
+			w.Header().Set("X-IPA-Rejection-Reason", "denied")
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		loginVersion = req.TLS.Version
+		http.SetCookie(w, &http.Cookie{Name: "ipa_session", Value: "correct-session-secret", Path: "/ipa"})
+	})
+	mux.HandleFunc("/ipa/session/json", func(w http.ResponseWriter, req *http.Request) {
+		jsonVersion = req.TLS.Version
+		handleJSON(w, req)
+	})
+	srv := httptest.NewUnstartedServer(mux)
+	srv.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+	srv.StartTLS()
+	defer srv.Close()
+
+	// Use the test certificate as the client certificate.
+	cert, err := tls.LoadX509KeyPair("test/cert.pem", "test/key.pem")
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	options := &CertificateConnectOptions{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+	client, err := ConnectWithCertificate(strings.TrimPrefix(srv.URL, "https://"), cert, options)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+
+	// Confirm the session works.
+	resp, err := client.Do(NewRequest("user_find", []interface{}{""}, map[string]interface{}{}))
+	if err != nil || resp.Result.Count != 2 {
+		t.Errorf("unexpected response: %v", err)
+	}
+
+	// Only the login is limited to TLS 1.2 for renegotiation.
+	if loginVersion != tls.VersionTLS12 || jsonVersion != tls.VersionTLS13 {
+		t.Errorf("unexpected TLS versions: login %x, json %x", loginVersion, jsonVersion)
+	}
+
+	// The provided transport should not have been modified.
+	if len(options.Transport.TLSClientConfig.Certificates) != 0 {
+		t.Errorf("expected provided transport to be unmodified")
+	}
+}
+
+// Confirm a custom authenticator can be used to establish the session.
+func TestCustomAuthenticator(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ipa/session/json", handleJSON)
+	srv := httptest.NewTLSServer(mux)
+	defer srv.Close()
+
+	// Authenticator which provides a session obtained elsewhere.
+	logins := 0
+	auth := AuthenticatorFunc(func(ctx context.Context, client *http.Client, baseURL string) error {
+		logins++
+		u, err := url.Parse(baseURL)
+		if err != nil {
+			return err
+		}
+		client.Jar.SetCookies(u, []*http.Cookie{{Name: "ipa_session", Value: "correct-session-secret", Path: "/ipa"}})
+		return nil
+	})
+	client, err := NewClient(strings.TrimPrefix(srv.URL, "https://"), WithTransport(srv.Client().Transport.(*http.Transport)), WithAuthenticator(auth))
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+
+	// Confirm the session works.
+	resp, err := client.Do(NewRequest("user_find", []interface{}{""}, map[string]interface{}{}))
+	if err != nil || resp.Result.Count != 2 {
+		t.Errorf("unexpected response: %v", err)
+	}
+	if logins != 1 {
+		t.Errorf("expected a single login: %d", logins)
+	}
+
+	// An authenticator is required.
+	_, err = NewClient("ipa.example.com")
+	if err == nil {
+		t.Errorf("expected error without an authenticator")
+	}
+}
+
+// Confirm an authenticator shared by clients changes an expired password safely, run with -race to check for data races.
+func TestSharedPasswordAuthenticator(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ipa/session/login_password", handleLogin)
+	mux.HandleFunc("/ipa/session/change_password", handleChangePassword)
+	srv := httptest.NewTLSServer(mux)
+	defer srv.Close()
+
+	var expired int32
+	auth := &PasswordAuthenticator{
+		User:     "expired",
+		Password: "oldpassword",
+		OnPasswordExpired: func(ctx context.Context, user string) (string, error) {
+			atomic.AddInt32(&expired, 1)
+			return "newpassword", nil
+		},
+	}
+	connect := func() error {
+		_, err := NewClient(
+			strings.TrimPrefix(srv.URL, "https://"),
+			WithTransport(srv.Client().Transport.(*http.Transport)),
+			WithAuthenticator(auth),
+			WithAPIVersion("2.245"),
+		)
+		return err
+	}
+
+	// Clients sharing the authenticator login at the same time.
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = connect()
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			t.Fatalf("error: %s", err)
+		}
+	}
+
+	// Later logins use the changed password.
+	calls := atomic.LoadInt32(&expired)
+	err := connect()
+	if err != nil || atomic.LoadInt32(&expired) != calls {
+		t.Errorf("expected changed password to be used: %v %d", err, expired)
+	}
+}
+
+// Confirm the client options configure the connection.
+func TestClientOptions(t *testing.T) {
+	// Server which expects the custom base path and user agent.
+	var userAgent string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/custom/ipa/session/login_password", func(w http.ResponseWriter, req *http.Request) {
+		userAgent = req.UserAgent()
+		handleLogin(w, req)
+	})
+	srv := httptest.NewTLSServer(mux)
+	defer srv.Close()
+
+	// Trust the test server's certificate as the IPA CA.
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	client, err := NewClient(
+		strings.TrimPrefix(srv.URL, "https://"),
+		WithCAPEM(caPEM),
+		WithBasePath("/custom/ipa"),
+		WithUserAgent("go-freeipa-test"),
+		WithTimeout(5*time.Second),
+		WithAuthenticator(&PasswordAuthenticator{User: "test", Password: "testpassword"}),
+		WithAPIVersion("2.245"),
+	)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	if userAgent != "go-freeipa-test" {
+		t.Errorf("unexpected user agent: %s", userAgent)
+	}
+	if client.client.Timeout != 5*time.Second {
+		t.Errorf("unexpected timeout: %s", client.client.Timeout)
+	}
+
+	// Without trusting the CA, the connection should fail.
+	_, err = NewClient(
+		strings.TrimPrefix(srv.URL, "https://"),
+		WithBasePath("/custom/ipa"),
+		WithAuthenticator(&PasswordAuthenticator{User: "test", Password: "testpassword"}),
+	)
+	if err == nil {
+		t.Errorf("expected certificate verification failure")
+	}
+
+	// CAs cannot be added to a provided http client.
+	_, err = NewClient("ipa.example.com", WithHTTPClient(srv.Client()), WithCAPEM(caPEM), WithAuthenticator(&PasswordAuthenticator{}))
+	if err == nil {
+		t.Errorf("expected error configuring CAs with an http client")
+	}
+
+	// Invalid CA data should be rejected.
+	_, err = NewClient("ipa.example.com", WithCAPEM([]byte("invalid")), WithAuthenticator(&PasswordAuthenticator{}))
+	if err == nil {
+		t.Errorf("expected invalid CA error")
+	}
+}
+
+// Confirm requests fail over to the next server when a server is unavailable.
+func TestServerFailover(t *testing.T) {
+	// Server which is down behind its front end.
+	down := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	// Server which is not listening.
+	closed := httptest.NewTLSServer(http.NotFoundHandler())
+	closed.Close()
+
+	// Working server.
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ipa/session/login_password", handleLogin)
+	mux.HandleFunc("/ipa/session/json", handleJSON)
+	up := httptest.NewTLSServer(mux)
+	defer up.Close()
+
+	upHost := strings.TrimPrefix(up.URL, "https://")
+	client, err := NewClient(
+		strings.TrimPrefix(down.URL, "https://"),
+		WithServers(strings.TrimPrefix(closed.URL, "https://"), upHost),
+		WithTransport(up.Client().Transport.(*http.Transport)),
+		WithAuthenticator(&PasswordAuthenticator{User: "test", Password: "testpassword"}),
+	)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+
+	// The request should be served by the working server.
+	resp, err := client.Do(NewRequest("user_find", []interface{}{""}, map[string]interface{}{}))
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	if resp.Server != upHost {
+		t.Errorf("unexpected server: %s", resp.Server)
+	}
+
+	// Confirm the health of each server was tracked.
+	statuses := client.Servers()
+	if statuses[0].Healthy || statuses[1].Healthy || !statuses[2].Healthy || !statuses[2].LoggedIn {
+		t.Errorf("unexpected server health: %+v", statuses)
+	}
+
+	// Unhealthy servers are tried last.
+	order := client.serverOrder()
+	if order[0].host != upHost {
+		t.Errorf("expected healthy server first: %s", order[0].host)
+	}
+
+	// Server which accepts logins, but fails after receiving other requests.
+	var upMethods []string
+	mux.HandleFunc("/ipa/session/change_password", func(w http.ResponseWriter, req *http.Request) {
+		upMethods = append(upMethods, "change_password")
+		handleChangePassword(w, req)
+	})
+	flakyMux := http.NewServeMux()
+	flakyMux.HandleFunc("/ipa/session/login_password", handleLogin)
+	flakyMux.HandleFunc("/", func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+	})
+	flaky := httptest.NewTLSServer(flakyMux)
+	defer flaky.Close()
+	upMux := http.NewServeMux()
+	upMux.HandleFunc("/", func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/ipa/session/json" {
+			res := new(Request)
+			data, _ := io.ReadAll(req.Body)
+			json.Unmarshal(data, res)
+			upMethods = append(upMethods, res.Method)
+			req.Body = io.NopCloser(bytes.NewReader(data))
+		}
+		mux.ServeHTTP(w, req)
+	})
+	up.Config.Handler = upMux
+
+	// Commands which make changes are not sent to another server once sent, as they may have been applied.
+	client, err = NewClient(
+		strings.TrimPrefix(flaky.URL, "https://"),
+		WithServers(upHost),
+		WithTransport(up.Client().Transport.(*http.Transport)),
+		WithAuthenticator(&PasswordAuthenticator{User: "test", Password: "testpassword"}),
+		WithAPIVersion("2.245"),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 1}),
+	)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	upMethods = nil
+	_, err = client.Do(NewRequest("user_add", []interface{}{"jdoe"}, map[string]interface{}{"givenname": "John", "sn": "Doe"}))
+	var ipaErr *Error
+	if !errors.As(err, &ipaErr) || ipaErr.StatusCode != http.StatusBadGateway || len(upMethods) != 0 {
+		t.Errorf("expected command which makes changes not to fail over: %v %v", err, upMethods)
+	}
+
+	// Password changes are never sent twice either.
+	client.servers[0].record(0, nil, false)
+	err = client.ChangePassword(context.Background(), "expired", "oldpassword", "newpassword", "")
+	if err == nil || len(upMethods) != 0 {
+		t.Errorf("expected password change not to fail over: %v %v", err, upMethods)
+	}
+
+	// Read-only commands fail over.
+	client.servers[0].record(0, nil, false)
+	_, err = client.Do(NewRequest("user_find", []interface{}{""}, map[string]interface{}{}))
+	if err != nil || len(upMethods) != 1 || upMethods[0] != "user_find" {
+		t.Errorf("expected read-only command to fail over: %v %v", err, upMethods)
+	}
+
+	// Commands which make changes fail over when they could not be sent.
+	client, err = NewClient(
+		strings.TrimPrefix(closed.URL, "https://"),
+		WithServers(upHost),
+		WithTransport(up.Client().Transport.(*http.Transport)),
+		WithAuthenticator(&PasswordAuthenticator{User: "test", Password: "testpassword"}),
+		WithAPIVersion("2.245"),
+	)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	client.servers[0].record(0, nil, false)
+	upMethods = nil
+	_, err = client.Do(NewRequest("user_add", []interface{}{"jdoe"}, map[string]interface{}{"givenname": "John", "sn": "Doe"}))
+	if err != nil || len(upMethods) != 1 || upMethods[0] != "user_add" {
+		t.Errorf("expected unsent command to fail over: %v %v", err, upMethods)
+	}
+}
+
+// Confirm the API version is sent with requests without modifying the request.
+func TestAPIVersion(t *testing.T) {
+	var version interface{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ipa/session/login_password", handleLogin)
+	mux.HandleFunc("/ipa/session/json", func(w http.ResponseWriter, req *http.Request) {
+		// Capture the version sent.
+		body, _ := io.ReadAll(req.Body)
+		res := new(Request)
+		json.Unmarshal(body, res)
+		if len(res.Params) > 1 {
+			version = res.Params[1].(map[string]interface{})["version"]
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		handleJSON(w, req)
+	})
+	srv := httptest.NewTLSServer(mux)
+	defer srv.Close()
+
+	// Connect with an API version override.
+	client, err := NewClient(
+		strings.TrimPrefix(srv.URL, "https://"),
+		WithTransport(srv.Client().Transport.(*http.Transport)),
+		WithAuthenticator(&PasswordAuthenticator{User: "test", Password: "testpassword"}),
+		WithAPIVersion("2.230"),
+	)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+
+	// The version should be sent, without being added to the caller's parameters.
+	params := map[string]interface{}{}
+	_, err = client.Do(NewRequest("user_find", []interface{}{""}, params))
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	if version != "2.230" {
+		t.Errorf("unexpected version: %v", version)
+	}
+	if _, ok := params["version"]; ok {
+		t.Errorf("expected parameters to be unmodified")
+	}
+	if client.APIVersionSource() != APIVersionConfigured {
+		t.Errorf("unexpected API version source: %v", client.APIVersionSource())
+	}
+
+	// Servers which reject the ping with an API error use the default version.
+	pingStatus := http.StatusOK
+	oldMux := http.NewServeMux()
+	oldMux.HandleFunc("/ipa/session/login_password", handleLogin)
+	oldMux.HandleFunc("/ipa/session/json", func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(pingStatus)
+		fmt.Fprintf(w, `{"result": null, "version": "4.4.0", "error": {"code": 905, "name": "CommandError", "message": "unknown command 'ping'", "data": {}}, "id": null, "principal": "test@EXAMPLE.COM"}`)
+	})
+	old := httptest.NewTLSServer(oldMux)
+	defer old.Close()
+	connect := func() (*Client, error) {
+		return NewClient(
+			strings.TrimPrefix(old.URL, "https://"),
+			WithTransport(old.Client().Transport.(*http.Transport)),
+			WithAuthenticator(&PasswordAuthenticator{User: "test", Password: "testpassword"}),
+			WithRetryPolicy(RetryPolicy{MaxAttempts: 1}),
+		)
+	}
+	client, err = connect()
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	if client.APIVersion() != DefaultAPIVersion || client.APIVersionSource() != APIVersionFallback {
+		t.Errorf("expected fallback API version: %s %v", client.APIVersion(), client.APIVersionSource())
+	}
+
+	// Other failures are not mistaken for an older server.
+	pingStatus = http.StatusInternalServerError
+	_, err = connect()
+	if err == nil {
+		t.Errorf("expected error negotiating API version")
+	}
+}
+
+// Confirm batch requests provide a result for each request.
+func TestBatch(t *testing.T) {
+	calls := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ipa/session/login_password", handleLogin)
+	mux.HandleFunc("/ipa/session/json", func(w http.ResponseWriter, req *http.Request) {
+		calls++
+		handleJSON(w, req)
+	})
+	srv := httptest.NewTLSServer(mux)
+	defer srv.Close()
+
+	client, err := NewClient(
+		strings.TrimPrefix(srv.URL, "https://"),
+		WithTransport(srv.Client().Transport.(*http.Transport)),
+		WithAuthenticator(&PasswordAuthenticator{User: "test", Password: "testpassword"}),
+		WithAPIVersion("2.245"),
+	)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+
+	// Make a batch which is split into two calls.
+	batch := NewBatch(
+		NewRequest("user_show", []interface{}{"admin"}, map[string]interface{}{}),
+		NewRequest("user_show", []interface{}{"missing"}, map[string]interface{}{}),
+	)
+	batch.Add(NewRequest("user_show", []interface{}{"johnny.bravo"}, map[string]interface{}{}))
+	batch.ChunkSize = 2
+	results, err := client.DoBatch(context.Background(), batch)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	if calls != 2 {
+		t.Errorf("expected 2 batch calls: %d", calls)
+	}
+
+	// Confirm each result.
+	if len(results) != 3 {
+		t.Fatalf("unexpected results: %v", results)
+	}
+	uid, _ := results[0].Response.GetString("uid")
+	if results[0].Err != nil || uid != "admin" {
+		t.Errorf("unexpected result: %v %s", results[0].Err, uid)
+	}
+	if results[1].Response != nil || !IsNotFound(results[1].Err) {
+		t.Errorf("expected not found: %v", results[1].Err)
+	}
+	uid, _ = results[2].Response.GetString("uid")
+	if results[2].Err != nil || uid != "johnny.bravo" || results[2].Request != batch.Requests[2] {
+		t.Errorf("unexpected result: %v %s", results[2].Err, uid)
+	}
 }
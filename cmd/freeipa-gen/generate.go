@@ -0,0 +1,475 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/grmrgecko/go-freeipa"
+)
+
+// Commands the client provides its own methods for, which are not generated.
+var skipCommands = map[string]bool{
+	"batch":  true,
+	"schema": true,
+}
+
+// Kinds of command, by attribute name, whose result is an entry of the command's object class.
+var entryCommands = map[string]bool{
+	"add":           true,
+	"mod":           true,
+	"show":          true,
+	"find":          true,
+	"add_member":    true,
+	"remove_member": true,
+}
+
+// Words written in upper case in Go names.
+var initialisms = map[string]bool{
+	"api":  true,
+	"ca":   true,
+	"dn":   true,
+	"dns":  true,
+	"gid":  true,
+	"http": true,
+	"id":   true,
+	"ip":   true,
+	"ldap": true,
+	"os":   true,
+	"otp":  true,
+	"sid":  true,
+	"ssh":  true,
+	"ttl":  true,
+	"uid":  true,
+	"uri":  true,
+	"url":  true,
+	"uuid": true,
+}
+
+// Field of a generated struct.
+type field struct {
+	name string
+	typ  string
+	tag  string
+	doc  string
+}
+
+// Names declared by the package the bindings are generated into, which generated names must not replace.
+type packageNames struct {
+	// Top level identifiers, such as types, functions, variables and constants.
+	idents map[string]bool
+	// Methods of Client.
+	methods map[string]bool
+}
+
+// Read the names declared by the Go files of the package in the directory, other than tests and the generated file.
+func readPackageNames(dir, generated string) (*packageNames, error) {
+	names := &packageNames{
+		idents:  make(map[string]bool),
+		methods: make(map[string]bool),
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") || name == generated {
+			continue
+		}
+		file, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, err
+		}
+		names.add(file)
+	}
+	return names, nil
+}
+
+// Add the top level names and Client methods declared in the file.
+func (n *packageNames) add(file *ast.File) {
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			if decl.Recv == nil {
+				n.idents[decl.Name.Name] = true
+				continue
+			}
+			typ := decl.Recv.List[0].Type
+			if star, ok := typ.(*ast.StarExpr); ok {
+				typ = star.X
+			}
+			if ident, ok := typ.(*ast.Ident); ok && ident.Name == "Client" {
+				n.methods[decl.Name.Name] = true
+			}
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					n.idents[spec.Name.Name] = true
+				case *ast.ValueSpec:
+					for _, ident := range spec.Names {
+						n.idents[ident.Name] = true
+					}
+				}
+			}
+		}
+	}
+}
+
+// State of the bindings being generated.
+type generator struct {
+	schema  *freeipa.Schema
+	names   *packageNames
+	buf     bytes.Buffer
+	classes map[string]string
+	imports map[string]bool
+	// Names of the types and methods generated so far.
+	types   map[string]bool
+	methods map[string]bool
+}
+
+// Generate the Go source of the bindings for the schema, avoiding the names the package already declares.
+func generate(schema *freeipa.Schema, names *packageNames) ([]byte, error) {
+	g := &generator{
+		schema:  schema,
+		names:   names,
+		classes: make(map[string]string),
+		imports: make(map[string]bool),
+		types:   make(map[string]bool),
+		methods: make(map[string]bool),
+	}
+
+	// Sort classes and commands, so the output only changes when the schema does.
+	classes := append([]*freeipa.SchemaClass(nil), schema.Classes...)
+	sort.Slice(classes, func(i, j int) bool { return classes[i].Name < classes[j].Name })
+	commands := append([]*freeipa.SchemaCommand(nil), schema.Commands...)
+	sort.Slice(commands, func(i, j int) bool { return commands[i].Name < commands[j].Name })
+
+	// Name the classes first, as commands refer to them in their results.
+	for _, class := range classes {
+		name := g.className(goName(class.Name))
+		g.classes[class.FullName] = name
+		g.classes[class.Name] = name
+	}
+	for _, class := range classes {
+		g.class(class)
+	}
+	for _, command := range commands {
+		if skipCommands[command.Name] {
+			continue
+		}
+		g.command(command)
+	}
+
+	// Add the header now the imports are known.
+	var out bytes.Buffer
+	out.WriteString("// Code generated by freeipa-gen from the API schema. DO NOT EDIT.\n\n")
+	out.WriteString("package freeipa\n\n")
+	if len(g.imports) != 0 {
+		var imports []string
+		for imp := range g.imports {
+			imports = append(imports, strconv.Quote(imp))
+		}
+		sort.Strings(imports)
+		fmt.Fprintf(&out, "import (\n%s\n)\n\n", strings.Join(imports, "\n"))
+	}
+	out.WriteString("// Fingerprint of the schema the command methods were generated from.\n")
+	fmt.Fprintf(&out, "const GeneratedSchemaFingerprint = %q\n\n", schema.Fingerprint)
+	out.Write(g.buf.Bytes())
+
+	return format.Source(out.Bytes())
+}
+
+// Check if a type name is not declared by the package or already generated.
+func (g *generator) typeFree(name string) bool {
+	return !g.names.idents[name] && !g.types[name]
+}
+
+// Get a free type name for a class, adding Entry and then a number if the name is taken.
+func (g *generator) className(name string) string {
+	candidate := name
+	for i := 1; !g.typeFree(candidate); i++ {
+		candidate = name + "Entry"
+		if i > 1 {
+			candidate += strconv.Itoa(i)
+		}
+	}
+	g.types[candidate] = true
+	return candidate
+}
+
+// Get a free name for the method of a command and its types, adding Command and then a number if any is taken.
+func (g *generator) commandName(name string) string {
+	candidate := name
+	for i := 1; ; i++ {
+		if !g.names.methods[candidate] && !g.methods[candidate] &&
+			g.typeFree(candidate+"Args") && g.typeFree(candidate+"Options") && g.typeFree(candidate+"Result") {
+			break
+		}
+		candidate = name + "Command"
+		if i > 1 {
+			candidate += strconv.Itoa(i)
+		}
+	}
+	g.methods[candidate] = true
+	g.types[candidate+"Args"] = true
+	g.types[candidate+"Options"] = true
+	g.types[candidate+"Result"] = true
+	return candidate
+}
+
+// Generate the struct for an object class.
+func (g *generator) class(class *freeipa.SchemaClass) {
+	name := g.classes[class.FullName]
+	var fields []field
+	used := make(map[string]bool)
+	for _, param := range class.Params {
+		fields = append(fields, field{
+			name: fieldName(param, used),
+			typ:  g.goType(param),
+			tag:  param.Name,
+			doc:  paramDoc(param),
+		})
+	}
+	g.writeStruct(name, fmt.Sprintf("Attributes of a %s entry.", class.Name), fields)
+}
+
+// Generate the structs and method for a command.
+func (g *generator) command(command *freeipa.SchemaCommand) {
+	g.imports["context"] = true
+	name := g.commandName(goName(command.Name))
+
+	// Split the parameters into positional arguments and options.
+	var args, options []field
+	var argParams []*freeipa.SchemaParam
+	argsUsed := make(map[string]bool)
+	optionsUsed := make(map[string]bool)
+	for _, param := range command.Params {
+		// The client sets the version.
+		if param.Name == "version" {
+			continue
+		}
+		typ := g.goType(param)
+		required := param.IsRequired()
+		if !required && !isReference(typ) {
+			typ = "*" + typ
+		}
+		if param.Positional {
+			argParams = append(argParams, param)
+			args = append(args, field{
+				name: fieldName(param, argsUsed),
+				typ:  typ,
+				doc:  paramDoc(param),
+			})
+			continue
+		}
+		tag := param.Name
+		if !required && isReference(typ) {
+			tag += ",omitempty"
+		}
+		options = append(options, field{
+			name: fieldName(param, optionsUsed),
+			typ:  typ,
+			tag:  tag,
+			doc:  paramDoc(param),
+		})
+	}
+
+	// Outputs are decoded from the response.
+	var outputs []field
+	outputsUsed := make(map[string]bool)
+	for _, param := range command.Output {
+		typ := g.goType(param)
+		if class, ok := g.classes[command.ObjClass]; ok && isEntryOutput(command, param) {
+			typ = class
+			if param.Multivalue || param.Type != "dict" {
+				typ = "[]" + class
+			}
+		}
+		outputs = append(outputs, field{
+			name: goFieldName(param.Name, outputsUsed),
+			typ:  typ,
+			tag:  param.Name,
+			doc:  paramDoc(param),
+		})
+	}
+
+	doc := firstSentence(command.Doc)
+	if len(args) != 0 {
+		g.writeStruct(name+"Args", fmt.Sprintf("Arguments of %s.", command.Name), args)
+	}
+	g.writeStruct(name+"Options", fmt.Sprintf("Options of %s.", command.Name), options)
+	g.writeStruct(name+"Result", fmt.Sprintf("Result of %s.", command.Name), outputs)
+
+	// Write the method.
+	fmt.Fprintf(&g.buf, "// %s\n", doc)
+	if len(args) != 0 {
+		fmt.Fprintf(&g.buf, "func (c *Client) %s(ctx context.Context, args %sArgs, options %sOptions) (*%sResult, error) {\n", name, name, name, name)
+
+		// Required arguments come first, followed by optional arguments which are added when set.
+		var required []string
+		for i, param := range argParams {
+			if param.IsRequired() {
+				required = append(required, "args."+args[i].name)
+			}
+		}
+		fmt.Fprintf(&g.buf, "\tparams := []interface{}{%s}\n", strings.Join(required, ", "))
+		for i, param := range argParams {
+			if param.IsRequired() {
+				continue
+			}
+			value := "args." + args[i].name
+			if !isReference(args[i].typ) {
+				value = "*" + value
+			}
+			fmt.Fprintf(&g.buf, "\tif args.%s != nil {\n\t\tparams = append(params, %s)\n\t}\n", args[i].name, value)
+		}
+	} else {
+		fmt.Fprintf(&g.buf, "func (c *Client) %s(ctx context.Context, options %sOptions) (*%sResult, error) {\n", name, name, name)
+		g.buf.WriteString("\tvar params []interface{}\n")
+	}
+	fmt.Fprintf(&g.buf, "\tresp, err := c.DoContext(ctx, NewRequest(%q, params, options))\n", command.Name)
+	g.buf.WriteString("\tif err != nil {\n\t\treturn nil, err\n\t}\n")
+	fmt.Fprintf(&g.buf, "\tresult := new(%sResult)\n", name)
+	g.buf.WriteString("\terr = resp.DecodeOutput(result)\n")
+	g.buf.WriteString("\tif err != nil {\n\t\treturn nil, err\n\t}\n")
+	g.buf.WriteString("\treturn result, nil\n}\n\n")
+}
+
+// Check if the output is the entry, or list of entries, of the command's object class.
+// Other results, such as the deletions which failed, are decoded as their own type.
+func isEntryOutput(command *freeipa.SchemaCommand, param *freeipa.SchemaParam) bool {
+	if param.Name != "result" {
+		return false
+	}
+	switch param.Type {
+	case "dict", "list", "tuple":
+	default:
+		return false
+	}
+
+	// The server describes entries as LDAP entries, otherwise the kind of command tells.
+	doc := strings.ToLower(param.Doc)
+	if strings.Contains(doc, "ldap entr") {
+		return true
+	}
+	return doc == "" && entryCommands[command.AttrName]
+}
+
+// Write a struct with a field for each parameter.
+func (g *generator) writeStruct(name, doc string, fields []field) {
+	fmt.Fprintf(&g.buf, "// %s\n", doc)
+	fmt.Fprintf(&g.buf, "type %s struct {\n", name)
+	for _, f := range fields {
+		if f.doc != "" {
+			fmt.Fprintf(&g.buf, "\t// %s\n", f.doc)
+		}
+		if f.tag != "" {
+			fmt.Fprintf(&g.buf, "\t%s %s `ipa:\"%s\"`\n", f.name, f.typ, f.tag)
+		} else {
+			fmt.Fprintf(&g.buf, "\t%s %s\n", f.name, f.typ)
+		}
+	}
+	g.buf.WriteString("}\n\n")
+}
+
+// Get the Go type of a parameter.
+func (g *generator) goType(param *freeipa.SchemaParam) string {
+	var typ string
+	switch param.Type {
+	case "str", "unicode", "Str", "IA5Str", "Password", "Principal", "DNSName", "CertificateSigningRequest":
+		typ = "string"
+	case "int", "Int":
+		typ = "int"
+	case "Decimal", "float":
+		typ = "float64"
+	case "bool", "Bool", "Flag":
+		typ = "bool"
+	case "datetime", "DateTime":
+		g.imports["time"] = true
+		typ = "time.Time"
+	case "bytes", "Bytes", "Certificate":
+		typ = "[]byte"
+	case "dict":
+		typ = "map[string]interface{}"
+	default:
+		typ = "interface{}"
+	}
+	if param.Multivalue {
+		typ = "[]" + typ
+	}
+	return typ
+}
+
+// Check if the type can be nil, so it does not need a pointer to be optional.
+func isReference(typ string) bool {
+	return strings.HasPrefix(typ, "[]") || strings.HasPrefix(typ, "map[") || typ == "interface{}"
+}
+
+// Get the Go field name of a parameter, using its command line name which is more readable than LDAP attribute names.
+func fieldName(param *freeipa.SchemaParam, used map[string]bool) string {
+	name := param.CLIName
+	if name == "" || used[goName(name)] {
+		name = param.Name
+	}
+	return goFieldName(name, used)
+}
+
+// Get a Go field name which is not yet used in the struct.
+func goFieldName(name string, used map[string]bool) string {
+	goname := goName(name)
+	for i := 2; used[goname]; i++ {
+		goname = fmt.Sprintf("%s%d", goName(name), i)
+	}
+	used[goname] = true
+	return goname
+}
+
+// Convert a FreeIPA name, such as user_add or no-members, to an exported Go name.
+func goName(name string) string {
+	parts := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var b strings.Builder
+	for _, part := range parts {
+		if initialisms[strings.ToLower(part)] {
+			b.WriteString(strings.ToUpper(part))
+			continue
+		}
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	goname := b.String()
+	if goname == "" || unicode.IsDigit(rune(goname[0])) {
+		goname = "X" + goname
+	}
+	return goname
+}
+
+// Get the documentation of a parameter.
+func paramDoc(param *freeipa.SchemaParam) string {
+	if param.Doc != "" {
+		return firstSentence(param.Doc)
+	}
+	return firstSentence(param.Label)
+}
+
+// Get the first line of documentation as a sentence.
+func firstSentence(doc string) string {
+	doc, _, _ = strings.Cut(strings.TrimSpace(doc), "\n")
+	doc = strings.TrimSpace(doc)
+	if doc != "" && !strings.HasSuffix(doc, ".") {
+		doc += "."
+	}
+	return doc
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/grmrgecko/go-freeipa"
+)
+
+// Read the names declared by the freeipa package.
+func testPackageNames(t *testing.T) *packageNames {
+	names, err := readPackageNames("../..", "api_generated.go")
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	return names
+}
+
+// Confirm the checked in bindings match those generated from the fixture.
+func TestGenerate(t *testing.T) {
+	f, err := os.Open("schema.json")
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	defer f.Close()
+	schema, err := freeipa.ParseSchema(f)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	src, err := generate(schema, testPackageNames(t))
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	generated, err := os.ReadFile("../../api_generated.go")
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	if !bytes.Equal(src, generated) {
+		t.Errorf("api_generated.go is out of date, run go generate")
+	}
+}
+
+// Confirm parameters are read as the server describes them, required unless marked otherwise
+// and positional when required unless marked otherwise.
+func TestGenerateSchemaFormat(t *testing.T) {
+	schema, err := freeipa.ParseSchema(strings.NewReader(`{"result": {"result": {"fingerprint": "test", "commands": [{
+		"name": "user_add", "full_name": "user_add/1",
+		"params": [
+			{"name": "uid", "cli_name": "login", "type": "str"},
+			{"name": "givenname", "cli_name": "first", "type": "str", "positional": false},
+			{"name": "loginshell", "cli_name": "shell", "type": "str", "required": false},
+			{"name": "version", "type": "str", "required": false}
+		],
+		"output": [{"name": "value", "type": "str"}]
+	}]}}, "error": null}`))
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	param := schema.Command("user_add").Params[0]
+	if !param.Required || !param.Positional {
+		t.Errorf("expected required positional argument: %+v", param)
+	}
+	src, err := generate(schema, testPackageNames(t))
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	for _, pattern := range []string{
+		`type UserAddArgs struct {\s+Login string`,
+		`First +string +` + "`" + `ipa:"givenname"` + "`",
+		`Shell +\*string +` + "`" + `ipa:"loginshell"` + "`",
+		`params := \[\]interface\{\}\{args\.Login\}`,
+	} {
+		if !regexp.MustCompile(pattern).Match(src) {
+			t.Errorf("expected generated source to match %s:\n%s", pattern, src)
+		}
+	}
+}
+
+// Confirm only results which are entries are typed as the command's object class.
+func TestGenerateEntryResults(t *testing.T) {
+	schema, err := freeipa.ParseSchema(strings.NewReader(`{"result": {"result": {"fingerprint": "test",
+	"classes": [{"name": "user", "full_name": "user/1", "params": [{"name": "uid", "type": "str"}]}],
+	"commands": [{
+		"name": "user_del", "full_name": "user_del/1", "obj_class": "user/1", "attr_name": "del",
+		"params": [{"name": "uid", "cli_name": "login", "type": "str", "multivalue": true}],
+		"output": [{"name": "result", "type": "dict", "doc": "List of deletions that failed"}]
+	}, {
+		"name": "user_show", "full_name": "user_show/1", "obj_class": "user/1", "attr_name": "show",
+		"params": [{"name": "uid", "cli_name": "login", "type": "str"}],
+		"output": [{"name": "result", "type": "dict", "doc": "A dictionary representing an LDAP entry"}]
+	}, {
+		"name": "user_find", "full_name": "user_find/1", "obj_class": "user/1", "attr_name": "find",
+		"params": [],
+		"output": [{"name": "result", "type": "list", "doc": "A list of LDAP entries"}]
+	}, {
+		"name": "user_undel", "full_name": "user_undel/1", "obj_class": "user/1", "attr_name": "undel",
+		"params": [],
+		"output": [{"name": "result", "type": "dict"}]
+	}]}}, "error": null}`))
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	src, err := generate(schema, testPackageNames(t))
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	for _, pattern := range []string{
+		`type UserDelResult struct {\s+// List of deletions that failed.\s+Result map\[string\]interface\{\} `,
+		`type UserShowResult struct {\s+// A dictionary representing an LDAP entry.\s+Result User `,
+		`type UserFindResult struct {\s+// A list of LDAP entries.\s+Result \[\]User `,
+		`type UserUndelResult struct {\s+Result map\[string\]interface\{\} `,
+	} {
+		if !regexp.MustCompile(pattern).Match(src) {
+			t.Errorf("expected generated source to match %s:\n%s", pattern, src)
+		}
+	}
+}
+
+// Confirm generated names do not collide with the package or with each other.
+func TestGenerateNameCollisions(t *testing.T) {
+	names := testPackageNames(t)
+	if !names.idents["Request"] || !names.idents["ErrNotFound"] || !names.methods["Do"] || names.idents["UserAdd"] {
+		t.Fatalf("unexpected package names")
+	}
+	schema, err := freeipa.ParseSchema(strings.NewReader(`{"result": {"result": {"fingerprint": "test",
+	"classes": [
+		{"name": "request", "full_name": "request/1", "params": []},
+		{"name": "request_entry", "full_name": "request_entry/1", "params": []}
+	],
+	"commands": [
+		{"name": "do", "full_name": "do/1", "params": [], "output": []},
+		{"name": "do-command", "full_name": "do-command/1", "params": [], "output": []},
+		{"name": "find_all", "full_name": "find_all/1", "params": [], "output": []},
+		{"name": "request_entry_args", "full_name": "request_entry_args/1", "params": [], "output": []},
+		{"name": "request_entry", "full_name": "request_entry/1", "params": [], "output": []}
+	]}}, "error": null}`))
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	src, err := generate(schema, names)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+
+	// Each generated name is declared once and not by the package.
+	file, err := parser.ParseFile(token.NewFileSet(), "", src, 0)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	generated := &packageNames{idents: make(map[string]bool), methods: make(map[string]bool)}
+	count := 0
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			count++
+			if names.methods[decl.Name.Name] {
+				t.Errorf("method %s collides with the package", decl.Name.Name)
+			}
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				if spec, ok := spec.(*ast.TypeSpec); ok {
+					count++
+					if names.idents[spec.Name.Name] {
+						t.Errorf("type %s collides with the package", spec.Name.Name)
+					}
+				}
+			}
+		}
+	}
+	// The names are unique if each declaration, other than the fingerprint constant, adds one.
+	generated.add(file)
+	if len(generated.idents)-1+len(generated.methods) != count {
+		t.Errorf("expected generated names to be unique:\n%s", src)
+	}
+	for _, name := range []string{"RequestEntry", "RequestEntryEntry", "DoCommandOptions", "DoCommandCommandOptions", "FindAllCommandOptions", "RequestEntryArgsOptions"} {
+		if !generated.idents[name] {
+			t.Errorf("expected %s to be generated:\n%s", name, src)
+		}
+	}
+}
+
+// Confirm FreeIPA names are converted to Go names.
+func TestGoName(t *testing.T) {
+	names := map[string]string{
+		"user_add":         "UserAdd",
+		"no-members":       "NoMembers",
+		"ip_address":       "IPAddress",
+		"dnsrecord_add":    "DnsrecordAdd",
+		"dns_is_enabled":   "DNSIsEnabled",
+		"3des":             "X3des",
+		"krbprincipalname": "Krbprincipalname",
+	}
+	for name, expected := range names {
+		if goName(name) != expected {
+			t.Errorf("unexpected name for %s: %s", name, goName(name))
+		}
+	}
+}
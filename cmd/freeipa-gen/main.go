@@ -0,0 +1,101 @@
+// Generate typed Go bindings for FreeIPA commands from the API schema.
+//
+// The schema is read from a fixture holding the response of the schema command, allowing
+// the bindings to be regenerated offline. A new fixture may be captured from a server with:
+//
+//	FREEIPA_PASSWORD=... freeipa-gen -capture ipa.example.com -user admin -schema schema.json
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/grmrgecko/go-freeipa"
+)
+
+func main() {
+	schemaPath := flag.String("schema", "schema.json", "Path of the schema fixture")
+	output := flag.String("o", "", "Path of the generated Go file, standard output if empty")
+	capture := flag.String("capture", "", "Host of a FreeIPA server to capture the schema fixture from")
+	user := flag.String("user", "admin", "User to login as when capturing, with the password in FREEIPA_PASSWORD")
+	caFile := flag.String("ca", freeipa.DefaultCAFile, "CA certificate of the server when capturing")
+	pkgDir := flag.String("pkg", "", "Directory of the package the bindings are for, to avoid its names, the directory of the output if empty")
+	flag.Parse()
+
+	// Capture the schema from a server, replacing the fixture.
+	if *capture != "" {
+		err := captureSchema(*capture, *user, os.Getenv("FREEIPA_PASSWORD"), *caFile, *schemaPath)
+		if err != nil {
+			log.Fatalf("error capturing schema: %s", err)
+		}
+		if *output == "" {
+			return
+		}
+	}
+
+	// Read the fixture.
+	f, err := os.Open(*schemaPath)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer f.Close()
+	schema, err := freeipa.ParseSchema(f)
+	if err != nil {
+		log.Fatalf("error parsing schema: %s", err)
+	}
+
+	// Read the names the package declares, other than those previously generated.
+	dir := *pkgDir
+	if dir == "" {
+		dir = filepath.Dir(*output)
+	}
+	names, err := readPackageNames(dir, filepath.Base(*output))
+	if err != nil {
+		log.Fatalf("error reading package names: %s", err)
+	}
+
+	// Generate the bindings.
+	src, err := generate(schema, names)
+	if err != nil {
+		log.Fatalf("error generating bindings: %s", err)
+	}
+	if *output == "" {
+		os.Stdout.Write(src)
+		return
+	}
+	err = os.WriteFile(*output, src, 0644)
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// Fetch the schema from the server and save the response as a fixture.
+func captureSchema(host, user, password, caFile, path string) error {
+	if password == "" {
+		return fmt.Errorf("no password set in FREEIPA_PASSWORD")
+	}
+	ctx := context.Background()
+	client, err := freeipa.NewClientContext(ctx, host,
+		freeipa.WithCAFile(caFile),
+		freeipa.WithAuthenticator(&freeipa.PasswordAuthenticator{User: user, Password: password}),
+	)
+	if err != nil {
+		return err
+	}
+	resp, err := client.DoContext(ctx, freeipa.NewRequest("schema", nil, nil))
+	if err != nil {
+		return err
+	}
+
+	// Save the response indented, so changes between versions are easy to review.
+	data, err := json.MarshalIndent(resp, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
@@ -0,0 +1,318 @@
+package freeipa
+
+import (
+	"encoding"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Types which decode themselves from a value in a FreeIPA response.
+// The value is as decoded from JSON, before any single value arrays or special types are unwrapped.
+type Unmarshaler interface {
+	UnmarshalIPA(value interface{}) error
+}
+
+// Types used in decoding.
+var (
+	unmarshalerType     = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	timeType            = reflect.TypeOf(time.Time{})
+)
+
+// Decode the result into v, which should be a pointer to a struct, map or slice.
+// Struct fields are matched to attributes by their ipa tag, such as `ipa:"krbcanonicalname"`, or their lowercase name.
+// Single value arrays are unwrapped, along with FreeIPA's __base64__, __datetime__ and __dns_name__ values.
+// Structs are reset before decoding, so fields for attributes missing from the result are left zero,
+// allowing a struct to be reused for each entry.
+func (r *Response) Decode(v interface{}) error {
+	if r.Result == nil {
+		return errors.New("no result in response")
+	}
+	return decodeInto(r.Result.Result, v)
+}
+
+// Decode all outputs of the command into v, such as the summary, value and result.
+// This is used by the generated command methods, where each output has a field.
+func (r *Response) DecodeOutput(v interface{}) error {
+	if r.Result == nil {
+		return errors.New("no result in response")
+	}
+	return decodeInto(r.Result.raw, v)
+}
+
+// Decode the result at the index into v, which should be a pointer to a struct or map.
+func (r *Response) DecodeAtIndex(index int, v interface{}) error {
+	dict, ok := r.DictAtIndex(index)
+	if !ok {
+		return fmt.Errorf("no result at index %d", index)
+	}
+	return decodeInto(dict, v)
+}
+
+// Decode the value into the pointer.
+func decodeInto(value interface{}, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return errors.New("decode requires a non-nil pointer")
+	}
+	return decodeValue(value, rv.Elem())
+}
+
+// Get the attribute name and options from a struct field's ipa tag.
+func fieldName(field reflect.StructField) (string, string) {
+	tag, ok := field.Tag.Lookup("ipa")
+	if !ok {
+		return strings.ToLower(field.Name), ""
+	}
+	name, options, _ := strings.Cut(tag, ",")
+	if name == "" {
+		name = strings.ToLower(field.Name)
+	}
+	return name, options
+}
+
+// Unwrap the special value types FreeIPA uses to encode binary data, dates and DNS names.
+func unwrapSpecial(value interface{}) (interface{}, error) {
+	dict, ok := value.(map[string]interface{})
+	if !ok || len(dict) != 1 {
+		return value, nil
+	}
+	if b, ok := dict["__base64__"].(string); ok {
+		return base64.StdEncoding.DecodeString(b)
+	}
+	if d, ok := dict["__datetime__"].(string); ok {
+		return time.Parse(LDAPGeneralizedTimeFormat, d)
+	}
+	if n, ok := dict["__dns_name__"].(string); ok {
+		return n, nil
+	}
+	return value, nil
+}
+
+// Decode the value into the reflected value.
+func decodeValue(value interface{}, rv reflect.Value) error {
+	// Allow types to decode themselves.
+	if rv.CanAddr() && rv.Addr().Type().Implements(unmarshalerType) {
+		return rv.Addr().Interface().(Unmarshaler).UnmarshalIPA(value)
+	}
+
+	// Missing values leave the zero value.
+	if value == nil {
+		rv.Set(reflect.Zero(rv.Type()))
+		return nil
+	}
+
+	// Allocate pointers, which allow optional attributes to be told apart from empty values.
+	if rv.Kind() == reflect.Pointer {
+		elem := reflect.New(rv.Type().Elem())
+		err := decodeValue(value, elem.Elem())
+		if err != nil {
+			return err
+		}
+		rv.Set(elem)
+		return nil
+	}
+
+	// Interfaces receive the value as is.
+	if rv.Kind() == reflect.Interface {
+		rv.Set(reflect.ValueOf(value))
+		return nil
+	}
+
+	// Slices receive each value, with single values becoming a single element.
+	if rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() != reflect.Uint8 {
+		values, ok := value.([]interface{})
+		if !ok {
+			values = []interface{}{value}
+		}
+		slice := reflect.MakeSlice(rv.Type(), len(values), len(values))
+		for i, v := range values {
+			err := decodeValue(v, slice.Index(i))
+			if err != nil {
+				return err
+			}
+		}
+		rv.Set(slice)
+		return nil
+	}
+
+	// Unwrap single value arrays for all other types.
+	if values, ok := value.([]interface{}); ok {
+		switch len(values) {
+		case 0:
+			rv.Set(reflect.Zero(rv.Type()))
+			return nil
+		case 1:
+			return decodeValue(values[0], rv)
+		}
+		return fmt.Errorf("cannot decode %d values into %s", len(values), rv.Type())
+	}
+
+	// Unwrap special values.
+	value, err := unwrapSpecial(value)
+	if err != nil {
+		return err
+	}
+
+	// Dates may also be provided as plain strings in the LDAP format.
+	if s, ok := value.(string); ok && rv.Type() == timeType {
+		t, err := time.Parse(LDAPGeneralizedTimeFormat, s)
+		if err != nil {
+			return err
+		}
+		rv.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	// Allow types to decode themselves from text.
+	if s, ok := value.(string); ok && rv.CanAddr() && rv.Addr().Type().Implements(textUnmarshalerType) {
+		return rv.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+
+	switch rv.Kind() {
+	case reflect.String:
+		switch s := value.(type) {
+		case string:
+			rv.SetString(s)
+		case []byte:
+			// Some text attributes, such as SSH public keys, are sent as base64.
+			rv.SetString(string(s))
+		default:
+			return fmt.Errorf("cannot decode %T into string", value)
+		}
+	case reflect.Bool:
+		switch b := value.(type) {
+		case bool:
+			rv.SetBool(b)
+		case string:
+			// LDAP booleans are upper case strings.
+			parsed, err := strconv.ParseBool(strings.ToLower(b))
+			if err != nil {
+				return err
+			}
+			rv.SetBool(parsed)
+		default:
+			return fmt.Errorf("cannot decode %T into bool", value)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		switch n := value.(type) {
+		case float64:
+			rv.SetInt(int64(n))
+		case string:
+			// LDAP integers are usually provided as strings.
+			parsed, err := strconv.ParseInt(n, 10, rv.Type().Bits())
+			if err != nil {
+				return err
+			}
+			rv.SetInt(parsed)
+		default:
+			return fmt.Errorf("cannot decode %T into %s", value, rv.Type())
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		switch n := value.(type) {
+		case float64:
+			rv.SetUint(uint64(n))
+		case string:
+			parsed, err := strconv.ParseUint(n, 10, rv.Type().Bits())
+			if err != nil {
+				return err
+			}
+			rv.SetUint(parsed)
+		default:
+			return fmt.Errorf("cannot decode %T into %s", value, rv.Type())
+		}
+	case reflect.Float32, reflect.Float64:
+		switch n := value.(type) {
+		case float64:
+			rv.SetFloat(n)
+		case string:
+			parsed, err := strconv.ParseFloat(n, rv.Type().Bits())
+			if err != nil {
+				return err
+			}
+			rv.SetFloat(parsed)
+		default:
+			return fmt.Errorf("cannot decode %T into %s", value, rv.Type())
+		}
+	case reflect.Slice:
+		// Byte slices are decoded from base64 values.
+		b, ok := value.([]byte)
+		if !ok {
+			return fmt.Errorf("cannot decode %T into %s", value, rv.Type())
+		}
+		rv.SetBytes(b)
+	case reflect.Map:
+		return decodeMap(value, rv)
+	case reflect.Struct:
+		if rv.Type() == timeType {
+			t, ok := value.(time.Time)
+			if !ok {
+				return fmt.Errorf("cannot decode %T into time", value)
+			}
+			rv.Set(reflect.ValueOf(t))
+			return nil
+		}
+		return decodeStruct(value, rv)
+	default:
+		return fmt.Errorf("cannot decode into %s", rv.Type())
+	}
+	return nil
+}
+
+// Decode a dictionary into a map with string keys.
+func decodeMap(value interface{}, rv reflect.Value) error {
+	dict, ok := value.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("cannot decode %T into %s", value, rv.Type())
+	}
+	if rv.Type().Key().Kind() != reflect.String {
+		return fmt.Errorf("cannot decode into %s", rv.Type())
+	}
+	m := reflect.MakeMapWithSize(rv.Type(), len(dict))
+	for k, v := range dict {
+		elem := reflect.New(rv.Type().Elem()).Elem()
+		err := decodeValue(v, elem)
+		if err != nil {
+			return fmt.Errorf("error decoding %s: %w", k, err)
+		}
+		m.SetMapIndex(reflect.ValueOf(k).Convert(rv.Type().Key()), elem)
+	}
+	rv.Set(m)
+	return nil
+}
+
+// Decode a dictionary into a struct using the field tags.
+func decodeStruct(value interface{}, rv reflect.Value) error {
+	dict, ok := value.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("cannot decode %T into %s", value, rv.Type())
+	}
+
+	// Reset the struct, so values from a previous entry are not kept for missing attributes.
+	t := rv.Type()
+	rv.Set(reflect.Zero(t))
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _ := fieldName(field)
+		if name == "-" {
+			continue
+		}
+		v, ok := dict[name]
+		if !ok {
+			continue
+		}
+		err := decodeValue(v, rv.Field(i))
+		if err != nil {
+			return fmt.Errorf("error decoding %s: %w", name, err)
+		}
+	}
+	return nil
+}
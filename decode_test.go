@@ -0,0 +1,112 @@
+package freeipa
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Custom type which decodes itself from the raw value.
+type upperString string
+
+// Decode the first value in upper case.
+func (u *upperString) UnmarshalIPA(value interface{}) error {
+	values := value.([]interface{})
+	*u = upperString(strings.ToUpper(values[0].(string)))
+	return nil
+}
+
+// User attributes for decoding tests.
+type testUser struct {
+	DN                    string
+	UID                   string      `ipa:"uid"`
+	HasKeytab             bool        `ipa:"has_keytab"`
+	KrbCanonicalName      string      `ipa:"krbcanonicalname"`
+	UIDNumber             int         `ipa:"uidnumber"`
+	KrbExtraData          []byte      `ipa:"krbextradata"`
+	KrbPasswordExpiration time.Time   `ipa:"krbpasswordexpiration"`
+	ObjectClass           []string    `ipa:"objectclass"`
+	Mail                  *string     `ipa:"mail"`
+	Telephone             *string     `ipa:"telephonenumber"`
+	GivenName             upperString `ipa:"givenname"`
+	Ignored               string      `ipa:"-"`
+}
+
+// Confirm results decode into structs.
+func TestDecode(t *testing.T) {
+	f, err := os.Open("test/user_add_response.json")
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	defer f.Close()
+	resp, err := ParseResponse(f)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+
+	user := new(testUser)
+	err = resp.Decode(user)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	if user.DN != "uid=username,cn=users,cn=accounts,dc=example,dc=com" || user.UID != "username" || !user.HasKeytab {
+		t.Errorf("unexpected user: %+v", user)
+	}
+	if user.KrbCanonicalName != "username@EXAMPLE.COM" || user.UIDNumber != 866001000 || len(user.KrbExtraData) != 27 {
+		t.Errorf("unexpected user: %+v", user)
+	}
+	if user.KrbPasswordExpiration.Year() != 2023 || len(user.ObjectClass) != 13 {
+		t.Errorf("unexpected user: %+v", user)
+	}
+	if user.Mail == nil || *user.Mail != "username@example.com" || user.Telephone != nil {
+		t.Errorf("unexpected optional attributes: %v %v", user.Mail, user.Telephone)
+	}
+	if user.GivenName != "FREEIPA" {
+		t.Errorf("unexpected custom decoding: %s", user.GivenName)
+	}
+
+	// Values from a previous entry are not kept for missing attributes when a struct is reused.
+	telephone := "555-0100"
+	user.Telephone = &telephone
+	err = resp.Decode(user)
+	if err != nil || user.Telephone != nil || user.UID != "username" {
+		t.Errorf("expected reused struct to be reset: %v %+v", err, user)
+	}
+
+	// Decode find results into a slice.
+	f, err = os.Open("test/user_find_response.json")
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	defer f.Close()
+	resp, err = ParseResponse(f)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	var users []testUser
+	err = resp.Decode(&users)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	if len(users) != 2 || users[1].UID != "johnny.bravo" {
+		t.Errorf("unexpected users: %+v", users)
+	}
+
+	// Decode a single result.
+	err = resp.DecodeAtIndex(0, user)
+	if err != nil || user.UID != "admin" {
+		t.Errorf("unexpected user: %v %+v", err, user)
+	}
+
+	// Multiple values cannot be decoded into a single value.
+	var single struct {
+		ObjectClass string `ipa:"objectclass"`
+	}
+	f, _ = os.Open("test/user_add_response.json")
+	defer f.Close()
+	resp, _ = ParseResponse(f)
+	if resp.Decode(&single) == nil {
+		t.Errorf("expected error decoding multiple values")
+	}
+}
@@ -0,0 +1,200 @@
+package freeipa
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Default location of the FreeIPA client configuration on enrolled machines.
+const DefaultConfPath = "/etc/ipa/default.conf"
+
+// Resolver used to look up SRV records, net.Resolver satisfies this interface.
+type Resolver interface {
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+// SRV records which FreeIPA servers publish.
+var discoverServices = []string{"ldap", "kerberos"}
+
+// Discover FreeIPA servers for the domain using the system resolver.
+func Discover(ctx context.Context, domain string) ([]string, error) {
+	return DiscoverWithResolver(ctx, net.DefaultResolver, domain)
+}
+
+// Discover FreeIPA servers for the domain from the _ldap._tcp and _kerberos._tcp SRV records.
+// Servers from the _ldap._tcp records are listed first, followed by any only published in _kerberos._tcp.
+// Within each service, servers are ordered by priority, then randomly by weight as described in RFC 2782.
+func DiscoverWithResolver(ctx context.Context, resolver Resolver, domain string) ([]string, error) {
+	var records []*net.SRV
+	var lookupErr error
+	for _, service := range discoverServices {
+		_, addrs, err := resolver.LookupSRV(ctx, service, "tcp", domain)
+		if err != nil {
+			lookupErr = err
+			continue
+		}
+		records = append(records, orderSRV(addrs, rand.Intn)...)
+	}
+
+	// The same servers are usually published in both records, so only keep the first of each.
+	var servers []string
+	seen := make(map[string]bool)
+	for _, record := range records {
+		host := strings.ToLower(strings.TrimSuffix(record.Target, "."))
+		// A target of "." means the service is not available.
+		if host == "" || seen[host] {
+			continue
+		}
+		seen[host] = true
+		servers = append(servers, host)
+	}
+
+	if len(servers) == 0 {
+		if lookupErr != nil {
+			return nil, fmt.Errorf("error discovering servers for %s: %w", domain, lookupErr)
+		}
+		return nil, fmt.Errorf("no servers found for %s", domain)
+	}
+	return servers, nil
+}
+
+// Order SRV records by priority, and within each priority randomly with the chance of each record being next
+// in proportion to its weight, as described in RFC 2782. The random function returns a number in [0, n).
+func orderSRV(records []*net.SRV, random func(n int) int) []*net.SRV {
+	ordered := append([]*net.SRV(nil), records...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Priority < ordered[j].Priority
+	})
+
+	// Order each group of records with the same priority.
+	for start := 0; start < len(ordered); {
+		end := start + 1
+		for end < len(ordered) && ordered[end].Priority == ordered[start].Priority {
+			end++
+		}
+		group := ordered[start:end]
+		sum := 0
+		for _, record := range group {
+			sum += int(record.Weight)
+		}
+
+		// Pick each record in turn, leaving records with no weight in their order once only they remain.
+		for sum > 0 && len(group) > 1 {
+			n := random(sum)
+			s := 0
+			for i, record := range group {
+				s += int(record.Weight)
+				if s > n {
+					copy(group[1:i+1], group[:i])
+					group[0] = record
+					break
+				}
+			}
+			sum -= int(group[0].Weight)
+			group = group[1:]
+		}
+		start = end
+	}
+	return ordered
+}
+
+// FreeIPA client configuration from default.conf.
+type DefaultConf struct {
+	Server    string
+	Domain    string
+	Realm     string
+	Host      string
+	XMLRPCURI string
+}
+
+// Load the FreeIPA client configuration, such as /etc/ipa/default.conf.
+func LoadDefaultConf(path string) (*DefaultConf, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseDefaultConf(f)
+}
+
+// Parse the FreeIPA client configuration from the reader.
+func ParseDefaultConf(r io.Reader) (*DefaultConf, error) {
+	conf := new(DefaultConf)
+	section := ""
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		// Skip blank lines and comments.
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		// Track the section, only the global section is used.
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		if section != "global" {
+			continue
+		}
+
+		// Parse the key/value pair.
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.TrimSpace(key) {
+		case "server":
+			conf.Server = value
+		case "domain":
+			conf.Domain = value
+		case "realm":
+			conf.Realm = value
+		case "host":
+			conf.Host = value
+		case "xmlrpc_uri":
+			conf.XMLRPCURI = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+// Get the servers from the configuration. The configured server is listed first,
+// followed by servers discovered from the domain's SRV records if a resolver is provided.
+func (c *DefaultConf) Servers(ctx context.Context, resolver Resolver) ([]string, error) {
+	var servers []string
+	if c.Server != "" {
+		servers = append(servers, c.Server)
+	}
+
+	// Add any other servers published for the domain.
+	if resolver != nil && c.Domain != "" {
+		discovered, err := DiscoverWithResolver(ctx, resolver, c.Domain)
+		if err != nil && len(servers) == 0 {
+			return nil, err
+		}
+		for _, host := range discovered {
+			if !strings.EqualFold(host, c.Server) {
+				servers = append(servers, host)
+			}
+		}
+	}
+
+	if len(servers) == 0 {
+		return nil, errors.New("no server or domain configured")
+	}
+	return servers, nil
+}
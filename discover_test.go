@@ -0,0 +1,110 @@
+package freeipa
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Resolver with fixed SRV records for testing.
+type fakeResolver map[string][]*net.SRV
+
+// Look up the records for the service.
+func (r fakeResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	target := "_" + service + "._" + proto + "." + name
+	addrs, ok := r[target]
+	if !ok {
+		return "", nil, &net.DNSError{Err: "no such host", Name: target, IsNotFound: true}
+	}
+	return target, addrs, nil
+}
+
+// Confirm servers are discovered in priority and weight order, with LDAP servers first.
+func TestDiscover(t *testing.T) {
+	resolver := fakeResolver{
+		"_ldap._tcp.example.com": {
+			{Target: "ipa3.example.com.", Priority: 10, Weight: 50},
+			{Target: "ipa1.example.com.", Priority: 0, Weight: 0},
+			{Target: "ipa2.example.com.", Priority: 0, Weight: 200},
+		},
+		"_kerberos._tcp.example.com": {
+			{Target: "ipa1.example.com.", Priority: 0, Weight: 100},
+			{Target: "ipa4.example.com.", Priority: 20, Weight: 100},
+			{Target: "ipa5.example.com.", Priority: 0, Weight: 100},
+		},
+	}
+
+	// Records with weight are always picked before records without, and Kerberos only servers follow LDAP servers.
+	servers, err := DiscoverWithResolver(context.Background(), resolver, "example.com")
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	expected := []string{"ipa2.example.com", "ipa1.example.com", "ipa3.example.com", "ipa5.example.com", "ipa4.example.com"}
+	if !reflect.DeepEqual(servers, expected) {
+		t.Errorf("unexpected servers: %v", servers)
+	}
+
+	// A domain without records should fail.
+	_, err = DiscoverWithResolver(context.Background(), resolver, "example.org")
+	if err == nil {
+		t.Errorf("expected error discovering unknown domain")
+	}
+
+	// The configured server should be listed before the discovered servers.
+	conf, err := ParseDefaultConf(strings.NewReader(`#File modified by ipa-client-install
+
+[global]
+basedn = dc=example,dc=com
+realm = EXAMPLE.COM
+domain = example.com
+server = ipa1.example.com
+host = client.example.com
+xmlrpc_uri = https://ipa1.example.com/ipa/xml
+enable_ra = True
+`))
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	if conf.Realm != "EXAMPLE.COM" || conf.Host != "client.example.com" {
+		t.Errorf("unexpected configuration: %+v", conf)
+	}
+	servers, err = conf.Servers(context.Background(), resolver)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	expected = []string{"ipa1.example.com", "ipa2.example.com", "ipa3.example.com", "ipa5.example.com", "ipa4.example.com"}
+	if !reflect.DeepEqual(servers, expected) {
+		t.Errorf("unexpected servers: %v", servers)
+	}
+}
+
+// Confirm records of the same priority are picked in proportion to their weight.
+func TestOrderSRV(t *testing.T) {
+	records := []*net.SRV{
+		{Target: "light", Priority: 0, Weight: 1},
+		{Target: "heavy", Priority: 0, Weight: 3},
+		{Target: "backup", Priority: 1, Weight: 100},
+	}
+	rnd := rand.New(rand.NewSource(1))
+	heavyFirst := 0
+	for i := 0; i < 1000; i++ {
+		ordered := orderSRV(records, rnd.Intn)
+		if len(ordered) != 3 || ordered[2].Target != "backup" {
+			t.Fatalf("unexpected order: %v", ordered)
+		}
+		if ordered[0].Target == "heavy" {
+			heavyFirst++
+		}
+	}
+	if heavyFirst < 650 || heavyFirst > 850 {
+		t.Errorf("expected heavy record first about 75%% of the time: %d", heavyFirst)
+	}
+
+	// The records provided are not reordered.
+	if records[0].Target != "light" {
+		t.Errorf("expected records to be unmodified")
+	}
+}
@@ -0,0 +1,143 @@
+package freeipa
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Binary value, sent to FreeIPA as base64, such as a certificate.
+type Binary []byte
+
+// Encode as a FreeIPA base64 value.
+func (b Binary) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]string{
+		"__base64__": base64.StdEncoding.EncodeToString(b),
+	})
+}
+
+// Date/time value, sent to FreeIPA in the LDAP generalized time format, such as krbprincipalexpiration.
+type DateTime time.Time
+
+// Encode as a FreeIPA date/time value.
+func (d DateTime) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]string{
+		"__datetime__": time.Time(d).UTC().Format(LDAPGeneralizedTimeFormat),
+	})
+}
+
+// DNS name value, such as a DNS record name or zone.
+type DNSName string
+
+// Encode as a FreeIPA DNS name value.
+func (n DNSName) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]string{
+		"__dns_name__": string(n),
+	})
+}
+
+// Convert Go values which have a FreeIPA encoding to the type which encodes them.
+func encodeValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case time.Time:
+		return DateTime(value)
+	case *time.Time:
+		if value == nil {
+			return nil
+		}
+		return DateTime(*value)
+	case []byte:
+		return Binary(value)
+	case []time.Time:
+		values := make([]interface{}, len(value))
+		for i, t := range value {
+			values[i] = DateTime(t)
+		}
+		return values
+	case [][]byte:
+		values := make([]interface{}, len(value))
+		for i, b := range value {
+			values[i] = Binary(b)
+		}
+		return values
+	}
+	return v
+}
+
+// Encode the options into a new map, from either a map or a struct with ipa tags.
+// Struct fields with the omitempty tag option are left out when empty, and nil pointers are always left out.
+func encodeOptions(v interface{}) (map[string]interface{}, error) {
+	options := make(map[string]interface{})
+	if v == nil {
+		return options, nil
+	}
+
+	// Copy maps, encoding their values.
+	if m, ok := v.(map[string]interface{}); ok {
+		for k, value := range m {
+			options[k] = encodeValue(value)
+		}
+		return options, nil
+	}
+
+	// Find the struct.
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return options, nil
+		}
+		rv = rv.Elem()
+	}
+
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("cannot encode options from %s", rv.Type())
+		}
+		iter := rv.MapRange()
+		for iter.Next() {
+			options[iter.Key().String()] = encodeValue(iter.Value().Interface())
+		}
+	case reflect.Struct:
+		t := rv.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, tagOptions := fieldName(field)
+			if name == "-" {
+				continue
+			}
+			value := rv.Field(i)
+			if value.Kind() == reflect.Pointer && value.IsNil() {
+				continue
+			}
+			if hasTagOption(tagOptions, "omitempty") && value.IsZero() {
+				continue
+			}
+			options[name] = encodeValue(value.Interface())
+		}
+	default:
+		return nil, fmt.Errorf("cannot encode options from %s", rv.Type())
+	}
+	return options, nil
+}
+
+// Check if the tag options include the option.
+func hasTagOption(options, option string) bool {
+	for _, o := range strings.Split(options, ",") {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
+
+// Get a pointer to the value, for setting optional fields such as those in generated options.
+func Ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,63 @@
+package freeipa
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// Options for encoding tests.
+type testUserModOptions struct {
+	GivenName   string    `ipa:"givenname,omitempty"`
+	Surname     string    `ipa:"sn,omitempty"`
+	Expiration  time.Time `ipa:"krbprincipalexpiration,omitempty"`
+	Certificate []byte    `ipa:"usercertificate,omitempty"`
+	LoginShell  *string   `ipa:"loginshell"`
+	NoMembers   bool      `ipa:"no_members"`
+	Ignored     string    `ipa:"-"`
+}
+
+// Confirm parameters are encoded in FreeIPA's format.
+func TestEncode(t *testing.T) {
+	// Special types encode to their wire format.
+	expiration := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	data, _ := json.Marshal([]interface{}{Binary("test"), DateTime(expiration), DNSName("www")})
+	if string(data) != `[{"__base64__":"dGVzdA=="},{"__datetime__":"20300102030405Z"},{"__dns_name__":"www"}]` {
+		t.Errorf("unexpected encoding: %s", data)
+	}
+
+	// Structs encode using their tags.
+	options, err := encodeOptions(&testUserModOptions{
+		GivenName:   "FreeIPA",
+		Expiration:  expiration,
+		Certificate: []byte("cert"),
+		Ignored:     "ignored",
+	})
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	data, _ = json.Marshal(options)
+	expected := `{"givenname":"FreeIPA","krbprincipalexpiration":{"__datetime__":"20300102030405Z"},"no_members":false,"usercertificate":{"__base64__":"Y2VydA=="}}`
+	if string(data) != expected {
+		t.Errorf("unexpected encoding: %s", data)
+	}
+
+	// Requests encode their options and add the version, without modifying the request.
+	client := &Client{apiVersion: "2.245"}
+	req := NewRequest("user_mod", []interface{}{"username"}, testUserModOptions{Surname: "Test"})
+	prepared, err := client.prepareRequest(req)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	data, _ = json.Marshal(prepared)
+	expected = `{"method":"user_mod","params":[["username"],{"no_members":false,"sn":"Test","version":"2.245"}]}`
+	if string(data) != expected {
+		t.Errorf("unexpected request: %s", data)
+	}
+
+	// Options which are not a map or struct cannot be encoded.
+	_, err = client.prepareRequest(NewRequest("user_show", nil, "invalid"))
+	if err == nil {
+		t.Errorf("expected error encoding invalid options")
+	}
+}
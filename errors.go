@@ -1,7 +1,9 @@
 package freeipa
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 )
 
@@ -131,6 +133,178 @@ const (
 	GenericErrorCode                          = 5000
 )
 
+// Sentinel errors for each FreeIPA error code. Each specific error wraps the family it belongs to,
+// so errors.Is(err, ErrExecution) matches a NotFound error while errors.Is(err, ErrNotFound) matches exactly.
+var (
+	ErrPublic                                = newCodeError(PublicErrorCode, "PublicError", nil)
+	ErrVersion                               = newCodeError(VersionErrorCode, "VersionError", ErrPublic)
+	ErrUnknown                               = newCodeError(UnknownErrorCode, "UnknownError", ErrPublic)
+	ErrInternal                              = newCodeError(InternalErrorCode, "InternalError", ErrPublic)
+	ErrServerInternal                        = newCodeError(ServerInternalErrorCode, "ServerInternalError", ErrPublic)
+	ErrCommand                               = newCodeError(CommandErrorCode, "CommandError", ErrPublic)
+	ErrServerCommand                         = newCodeError(ServerCommandErrorCode, "ServerCommandError", ErrPublic)
+	ErrNetwork                               = newCodeError(NetworkErrorCode, "NetworkError", ErrPublic)
+	ErrServerNetwork                         = newCodeError(ServerNetworkErrorCode, "ServerNetworkError", ErrPublic)
+	ErrJSON                                  = newCodeError(JSONErrorCode, "JSONError", ErrPublic)
+	ErrXMLRPCMarshall                        = newCodeError(XMLRPCMarshallErrorCode, "XMLRPCMarshallError", ErrPublic)
+	ErrReferer                               = newCodeError(RefererErrorCode, "RefererError", ErrPublic)
+	ErrEnvironment                           = newCodeError(EnvironmentErrorCode, "EnvironmentError", ErrPublic)
+	ErrSystemEncoding                        = newCodeError(SystemEncodingErrorCode, "SystemEncodingError", ErrPublic)
+	ErrAuthentication                        = newCodeError(AuthenticationErrorCode, "AuthenticationError", nil)
+	ErrKerberos                              = newCodeError(KerberosErrorCode, "KerberosError", ErrAuthentication)
+	ErrCCache                                = newCodeError(CCacheErrorCode, "CCacheError", ErrKerberos)
+	ErrService                               = newCodeError(ServiceErrorCode, "ServiceError", ErrKerberos)
+	ErrNoCCache                              = newCodeError(NoCCacheErrorCode, "NoCCacheError", ErrKerberos)
+	ErrTicketExpired                         = newCodeError(TicketExpiredCode, "TicketExpired", ErrKerberos)
+	ErrBadCCachePerms                        = newCodeError(BadCCachePermsCode, "BadCCachePerms", ErrKerberos)
+	ErrBadCCacheFormat                       = newCodeError(BadCCacheFormatCode, "BadCCacheFormat", ErrKerberos)
+	ErrCannotResolveKDC                      = newCodeError(CannotResolveKDCCode, "CannotResolveKDC", ErrKerberos)
+	ErrSession                               = newCodeError(SessionErrorCode, "SessionError", ErrAuthentication)
+	ErrInvalidSessionPassword                = newCodeError(InvalidSessionPasswordCode, "InvalidSessionPassword", ErrSession)
+	ErrPasswordExpired                       = newCodeError(PasswordExpiredCode, "PasswordExpired", ErrSession)
+	ErrKrbPrincipalExpired                   = newCodeError(KrbPrincipalExpiredCode, "KrbPrincipalExpired", ErrSession)
+	ErrUserLocked                            = newCodeError(UserLockedCode, "UserLocked", ErrSession)
+	ErrAuthorization                         = newCodeError(AuthorizationErrorCode, "AuthorizationError", nil)
+	ErrACI                                   = newCodeError(ACIErrorCode, "ACIError", ErrAuthorization)
+	ErrInvocation                            = newCodeError(InvocationErrorCode, "InvocationError", nil)
+	ErrEncoding                              = newCodeError(EncodingErrorCode, "EncodingError", ErrInvocation)
+	ErrBinaryEncoding                        = newCodeError(BinaryEncodingErrorCode, "BinaryEncodingError", ErrInvocation)
+	ErrZeroArgument                          = newCodeError(ZeroArgumentErrorCode, "ZeroArgumentError", ErrInvocation)
+	ErrMaxArgument                           = newCodeError(MaxArgumentErrorCode, "MaxArgumentError", ErrInvocation)
+	ErrOption                                = newCodeError(OptionErrorCode, "OptionError", ErrInvocation)
+	ErrOverlap                               = newCodeError(OverlapErrorCode, "OverlapError", ErrInvocation)
+	ErrRequirement                           = newCodeError(RequirementErrorCode, "RequirementError", ErrInvocation)
+	ErrConversion                            = newCodeError(ConversionErrorCode, "ConversionError", ErrInvocation)
+	ErrValidation                            = newCodeError(ValidationErrorCode, "ValidationError", ErrInvocation)
+	ErrNoSuchNamespace                       = newCodeError(NoSuchNamespaceErrorCode, "NoSuchNamespaceError", ErrInvocation)
+	ErrPasswordMismatch                      = newCodeError(PasswordMismatchCode, "PasswordMismatch", ErrInvocation)
+	ErrNotImplemented                        = newCodeError(NotImplementedErrorCode, "NotImplementedError", ErrInvocation)
+	ErrNotConfigured                         = newCodeError(NotConfiguredErrorCode, "NotConfiguredError", ErrInvocation)
+	ErrPromptFailed                          = newCodeError(PromptFailedCode, "PromptFailed", ErrInvocation)
+	ErrDeprecation                           = newCodeError(DeprecationErrorCode, "DeprecationError", ErrInvocation)
+	ErrNotAForestRoot                        = newCodeError(NotAForestRootErrorCode, "NotAForestRootError", ErrInvocation)
+	ErrExecution                             = newCodeError(ExecutionErrorCode, "ExecutionError", nil)
+	ErrNotFound                              = newCodeError(NotFoundCode, "NotFound", ErrExecution)
+	ErrDuplicateEntry                        = newCodeError(DuplicateEntryCode, "DuplicateEntry", ErrExecution)
+	ErrHostService                           = newCodeError(HostServiceCode, "HostService", ErrExecution)
+	ErrMalformedServicePrincipal             = newCodeError(MalformedServicePrincipalCode, "MalformedServicePrincipal", ErrExecution)
+	ErrRealmMismatch                         = newCodeError(RealmMismatchCode, "RealmMismatch", ErrExecution)
+	ErrRequiresRoot                          = newCodeError(RequiresRootCode, "RequiresRoot", ErrExecution)
+	ErrAlreadyPosixGroup                     = newCodeError(AlreadyPosixGroupCode, "AlreadyPosixGroup", ErrExecution)
+	ErrMalformedUserPrincipal                = newCodeError(MalformedUserPrincipalCode, "MalformedUserPrincipal", ErrExecution)
+	ErrAlreadyActive                         = newCodeError(AlreadyActiveCode, "AlreadyActive", ErrExecution)
+	ErrAlreadyInactive                       = newCodeError(AlreadyInactiveCode, "AlreadyInactive", ErrExecution)
+	ErrHasNSAccountLock                      = newCodeError(HasNSAccountLockCode, "HasNSAccountLock", ErrExecution)
+	ErrNotGroupMember                        = newCodeError(NotGroupMemberCode, "NotGroupMember", ErrExecution)
+	ErrRecursiveGroup                        = newCodeError(RecursiveGroupCode, "RecursiveGroup", ErrExecution)
+	ErrAlreadyGroupMember                    = newCodeError(AlreadyGroupMemberCode, "AlreadyGroupMember", ErrExecution)
+	ErrBase64Decode                          = newCodeError(Base64DecodeErrorCode, "Base64DecodeError", ErrExecution)
+	ErrRemoteRetrieve                        = newCodeError(RemoteRetrieveErrorCode, "RemoteRetrieveError", ErrExecution)
+	ErrSameGroup                             = newCodeError(SameGroupErrorCode, "SameGroupError", ErrExecution)
+	ErrDefaultGroup                          = newCodeError(DefaultGroupErrorCode, "DefaultGroupError", ErrExecution)
+	ErrDNSNotARecord                         = newCodeError(DNSNotARecordErrorCode, "DNSNotARecordError", ErrExecution)
+	ErrManagedGroup                          = newCodeError(ManagedGroupErrorCode, "ManagedGroupError", ErrExecution)
+	ErrManagedPolicy                         = newCodeError(ManagedPolicyErrorCode, "ManagedPolicyError", ErrExecution)
+	ErrFile                                  = newCodeError(FileErrorCode, "FileError", ErrExecution)
+	ErrNoCertificate                         = newCodeError(NoCertificateErrorCode, "NoCertificateError", ErrExecution)
+	ErrManagedGroupExists                    = newCodeError(ManagedGroupExistsErrorCode, "ManagedGroupExistsError", ErrExecution)
+	ErrReverseMember                         = newCodeError(ReverseMemberErrorCode, "ReverseMemberError", ErrExecution)
+	ErrAttrValueNotFound                     = newCodeError(AttrValueNotFoundCode, "AttrValueNotFound", ErrExecution)
+	ErrSingleMatchExpected                   = newCodeError(SingleMatchExpectedCode, "SingleMatchExpected", ErrExecution)
+	ErrAlreadyExternalGroup                  = newCodeError(AlreadyExternalGroupCode, "AlreadyExternalGroup", ErrExecution)
+	ErrExternalGroupViolation                = newCodeError(ExternalGroupViolationCode, "ExternalGroupViolation", ErrExecution)
+	ErrPosixGroupViolation                   = newCodeError(PosixGroupViolationCode, "PosixGroupViolation", ErrExecution)
+	ErrEmptyResult                           = newCodeError(EmptyResultCode, "EmptyResult", ErrExecution)
+	ErrInvalidDomainLevel                    = newCodeError(InvalidDomainLevelErrorCode, "InvalidDomainLevelError", ErrExecution)
+	ErrServerRemoval                         = newCodeError(ServerRemovalErrorCode, "ServerRemovalError", ErrExecution)
+	ErrOperationNotSupportedForPrincipalType = newCodeError(OperationNotSupportedForPrincipalTypeCode, "OperationNotSupportedForPrincipalType", ErrExecution)
+	ErrHTTPRequest                           = newCodeError(HTTPRequestErrorCode, "HTTPRequestError", ErrExecution)
+	ErrRedundantMappingRule                  = newCodeError(RedundantMappingRuleCode, "RedundantMappingRule", ErrExecution)
+	ErrCSRTemplate                           = newCodeError(CSRTemplateErrorCode, "CSRTemplateError", ErrExecution)
+	ErrAlreadyContainsValue                  = newCodeError(AlreadyContainsValueErrorCode, "AlreadyContainsValueError", ErrExecution)
+	ErrBuiltin                               = newCodeError(BuiltinErrorCode, "BuiltinError", ErrExecution)
+	ErrHelp                                  = newCodeError(HelpErrorCode, "HelpError", ErrBuiltin)
+	ErrLDAP                                  = newCodeError(LDAPErrorCode, "LDAPError", ErrExecution)
+	ErrMidairCollision                       = newCodeError(MidairCollisionCode, "MidairCollision", ErrLDAP)
+	ErrEmptyModlist                          = newCodeError(EmptyModlistCode, "EmptyModlist", ErrLDAP)
+	ErrDatabase                              = newCodeError(DatabaseErrorCode, "DatabaseError", ErrLDAP)
+	ErrLimitsExceeded                        = newCodeError(LimitsExceededCode, "LimitsExceeded", ErrLDAP)
+	ErrObjectclassViolation                  = newCodeError(ObjectclassViolationCode, "ObjectclassViolation", ErrLDAP)
+	ErrNotAllowedOnRDN                       = newCodeError(NotAllowedOnRDNCode, "NotAllowedOnRDN", ErrLDAP)
+	ErrOnlyOneValueAllowed                   = newCodeError(OnlyOneValueAllowedCode, "OnlyOneValueAllowed", ErrLDAP)
+	ErrInvalidSyntax                         = newCodeError(InvalidSyntaxCode, "InvalidSyntax", ErrLDAP)
+	ErrBadSearchFilter                       = newCodeError(BadSearchFilterCode, "BadSearchFilter", ErrLDAP)
+	ErrNotAllowedOnNonLeaf                   = newCodeError(NotAllowedOnNonLeafCode, "NotAllowedOnNonLeaf", ErrLDAP)
+	ErrDatabaseTimeout                       = newCodeError(DatabaseTimeoutCode, "DatabaseTimeout", ErrLDAP)
+	ErrDNSDataMismatch                       = newCodeError(DNSDataMismatchCode, "DNSDataMismatch", ErrLDAP)
+	ErrTaskTimeout                           = newCodeError(TaskTimeoutCode, "TaskTimeout", ErrLDAP)
+	ErrTimeLimitExceeded                     = newCodeError(TimeLimitExceededCode, "TimeLimitExceeded", ErrLDAP)
+	ErrSizeLimitExceeded                     = newCodeError(SizeLimitExceededCode, "SizeLimitExceeded", ErrLDAP)
+	ErrAdminLimitExceeded                    = newCodeError(AdminLimitExceededCode, "AdminLimitExceeded", ErrLDAP)
+	ErrCertificate                           = newCodeError(CertificateErrorCode, "CertificateError", ErrExecution)
+	ErrCertificateOperation                  = newCodeError(CertificateOperationErrorCode, "CertificateOperationError", ErrCertificate)
+	ErrCertificateFormat                     = newCodeError(CertificateFormatErrorCode, "CertificateFormatError", ErrCertificate)
+	ErrMutuallyExclusive                     = newCodeError(MutuallyExclusiveErrorCode, "MutuallyExclusiveError", ErrCertificate)
+	ErrNonFatal                              = newCodeError(NonFatalErrorCode, "NonFatalError", ErrCertificate)
+	ErrAlreadyRegistered                     = newCodeError(AlreadyRegisteredErrorCode, "AlreadyRegisteredError", ErrCertificate)
+	ErrNotRegistered                         = newCodeError(NotRegisteredErrorCode, "NotRegisteredError", ErrCertificate)
+	ErrDependentEntry                        = newCodeError(DependentEntryCode, "DependentEntry", ErrCertificate)
+	ErrLastMember                            = newCodeError(LastMemberErrorCode, "LastMemberError", ErrCertificate)
+	ErrProtectedEntry                        = newCodeError(ProtectedEntryErrorCode, "ProtectedEntryError", ErrCertificate)
+	ErrCertificateInvalid                    = newCodeError(CertificateInvalidErrorCode, "CertificateInvalidError", ErrCertificate)
+	ErrSchemaUpToDate                        = newCodeError(SchemaUpToDateCode, "SchemaUpToDate", ErrCertificate)
+	ErrDNS                                   = newCodeError(DNSErrorCode, "DNSError", ErrExecution)
+	ErrDNSResolver                           = newCodeError(DNSResolverErrorCode, "DNSResolverError", ErrDNS)
+	ErrTrust                                 = newCodeError(TrustErrorCode, "TrustError", ErrExecution)
+	ErrTrustTopologyConflict                 = newCodeError(TrustTopologyConflictErrorCode, "TrustTopologyConflictError", ErrTrust)
+	ErrGeneric                               = newCodeError(GenericErrorCode, "GenericError", nil)
+)
+
+// Sentinel errors registered by error code.
+var codeErrors = make(map[int]*codeError)
+
+// Sentinel error for an error code, chained to the error family it belongs to.
+type codeError struct {
+	code   int
+	name   string
+	parent error
+}
+
+// Make a sentinel error and register it by its error code.
+func newCodeError(code int, name string, parent error) *codeError {
+	e := &codeError{
+		code:   code,
+		name:   name,
+		parent: parent,
+	}
+	codeErrors[code] = e
+	return e
+}
+
+// The error name as FreeIPA defines it.
+func (e *codeError) Error() string {
+	return e.name
+}
+
+// Unwrap to the family this error belongs to.
+func (e *codeError) Unwrap() error {
+	return e.parent
+}
+
+// Find the sentinel error for an error code, falling back to the family by code range if the code is unknown.
+func sentinelForCode(code int) error {
+	if e, ok := codeErrors[code]; ok {
+		return e
+	}
+	if e, ok := codeErrors[code/100*100]; ok {
+		return e
+	}
+	if e, ok := codeErrors[code/1000*1000]; ok {
+		return e
+	}
+	return nil
+}
+
 // Authentication rejection reasons.
 const (
 	passwordExpiredUnauthorizedReason        = "password-expired"
@@ -140,23 +314,250 @@ const (
 	rejectionReasonHTTPHeader                = "X-Ipa-Rejection-Reason"
 )
 
+// Password change results.
+const (
+	okPasswordChangeResult              = "ok"
+	invalidPasswordPasswordChangeResult = "invalid-password"
+	policyErrorPasswordChangeResult     = "policy-error"
+	passwordChangeResultHTTPHeader      = "X-Ipa-Pwchange-Result"
+	passwordChangePolicyHTTPHeader      = "X-Ipa-Pwchange-Policy-Error"
+)
+
+// Error returned by the FreeIPA API, or built from an HTTP rejection.
+type Error struct {
+	Code       int
+	Name       string
+	Message    string
+	Data       map[string]interface{}
+	StatusCode int
+}
+
+// Make an error from a message provided in an API response.
+func newErrorFromMessage(m *Message) *Error {
+	return &Error{
+		Code:    m.Code,
+		Name:    m.Name,
+		Message: m.Message,
+		Data:    m.Data,
+	}
+}
+
+// Make an error for the code, as FreeIPA would return it.
+func newError(code int, message string, data map[string]interface{}) *Error {
+	e := &Error{
+		Code:    code,
+		Message: message,
+		Data:    data,
+	}
+	if sentinel, ok := codeErrors[code]; ok {
+		e.Name = sentinel.name
+	}
+	return e
+}
+
+// Format the error the same way FreeIPA does in its own clients.
+func (e *Error) Error() string {
+	if e.Name == "" {
+		return fmt.Sprintf("%s (%d)", e.Message, e.Code)
+	}
+	return fmt.Sprintf("%s (%d): %s", e.Name, e.Code, e.Message)
+}
+
+// Unwrap to the sentinel error for the error code, allowing errors.Is to match the code and its family.
+func (e *Error) Unwrap() error {
+	return sentinelForCode(e.Code)
+}
+
+// Check if the error chain contains a FreeIPA error with one of the provided codes.
+func hasErrorCode(err error, code int) bool {
+	var e *Error
+	if !errors.As(err, &e) {
+		return false
+	}
+	return e.Code == code
+}
+
+// Check if the error is due to the requested entry not existing.
+func IsNotFound(err error) bool {
+	return hasErrorCode(err, NotFoundCode)
+}
+
+// Check if the error is due to the entry already existing.
+func IsDuplicate(err error) bool {
+	return hasErrorCode(err, DuplicateEntryCode)
+}
+
+// Check if the error is due to the user not being authorized to perform the request.
+func IsAuthorization(err error) bool {
+	return errors.Is(err, ErrAuthorization)
+}
+
+// Check if the error is due to authentication failing.
+func IsAuthentication(err error) bool {
+	return errors.Is(err, ErrAuthentication)
+}
+
+// Reason FreeIPA gave for rejecting authentication.
+type AuthReason int
+
+// Authentication rejection reasons mapped from the rejection reason header.
+const (
+	AuthReasonUnknown AuthReason = iota
+	AuthReasonInvalidPassword
+	AuthReasonPasswordExpired
+	AuthReasonPrincipalExpired
+	AuthReasonUserLocked
+)
+
+// Readable name of the authentication rejection reason.
+func (r AuthReason) String() string {
+	switch r {
+	case AuthReasonInvalidPassword:
+		return "invalid password"
+	case AuthReasonPasswordExpired:
+		return "password expired"
+	case AuthReasonPrincipalExpired:
+		return "principal expired"
+	case AuthReasonUserLocked:
+		return "user locked"
+	}
+	return "unknown"
+}
+
+// Error returned when FreeIPA rejects a login.
+type AuthError struct {
+	Reason          AuthReason
+	RejectionReason string
+	StatusCode      int
+	Body            string
+	// Set when a one-time password was sent, in which case an invalid password may be due to the token.
+	OTP bool
+	err *Error
+}
+
+// Provide the underlying FreeIPA error message.
+func (e *AuthError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap to the FreeIPA error, allowing errors.Is to match the error code sentinels.
+func (e *AuthError) Unwrap() error {
+	return e.err
+}
+
+// Error for responses with an unexpected HTTP status, such as server errors from the web server.
+func unexpectedHTTPError(resp *http.Response) error {
+	return &Error{
+		Code:       GenericErrorCode,
+		Message:    fmt.Sprintf("unexpected http status code: %d", resp.StatusCode),
+		StatusCode: resp.StatusCode,
+	}
+}
+
+// Maximum amount of a rejected login response body to keep in errors.
+const maxAuthErrorBody = 64 * 1024
+
 // Add information from the rejection reason header to unauthorized error.
 func unauthorizedHTTPError(resp *http.Response) error {
 	var errorCode int
+	var reason AuthReason
 	rejectionReason := resp.Header.Get(rejectionReasonHTTPHeader)
 
 	switch rejectionReason {
 	case passwordExpiredUnauthorizedReason:
 		errorCode = PasswordExpiredCode
+		reason = AuthReasonPasswordExpired
 	case invalidSessionPasswordUnauthorizedReason:
 		errorCode = InvalidSessionPasswordCode
+		reason = AuthReasonInvalidPassword
 	case krbPrincipalExpiredUnauthorizedReason:
 		errorCode = KrbPrincipalExpiredCode
+		reason = AuthReasonPrincipalExpired
 	case userLockedUnauthorizedReason:
 		errorCode = UserLockedCode
+		reason = AuthReasonUserLocked
 
 	default:
 		errorCode = GenericErrorCode
+		reason = AuthReasonUnknown
+	}
+
+	// Keep the response body, as it usually explains the rejection in more detail.
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxAuthErrorBody))
+
+	return &AuthError{
+		Reason:          reason,
+		RejectionReason: rejectionReason,
+		StatusCode:      resp.StatusCode,
+		Body:            string(body),
+		err: &Error{
+			Code:       errorCode,
+			Message:    fmt.Sprintf("unauthorized response <%s>", rejectionReason),
+			StatusCode: resp.StatusCode,
+		},
+	}
+}
+
+// Reason FreeIPA gave for rejecting a password change.
+type PasswordChangeReason int
+
+// Password change rejection reasons mapped from the password change result header.
+const (
+	PasswordChangeReasonUnknown PasswordChangeReason = iota
+	PasswordChangeReasonInvalidPassword
+	PasswordChangeReasonPolicy
+)
+
+// Error returned when FreeIPA rejects a password change.
+type PasswordChangeError struct {
+	Reason      PasswordChangeReason
+	Result      string
+	PolicyError string
+	StatusCode  int
+}
+
+// Describe why the password change was rejected.
+func (e *PasswordChangeError) Error() string {
+	if e.PolicyError != "" {
+		return fmt.Sprintf("password change rejected <%s>: %s", e.Result, e.PolicyError)
+	}
+	return fmt.Sprintf("password change rejected <%s>", e.Result)
+}
+
+// An invalid old password is the same failure as an invalid login password.
+func (e *PasswordChangeError) Unwrap() error {
+	if e.Reason == PasswordChangeReasonInvalidPassword {
+		return ErrInvalidSessionPassword
+	}
+	return nil
+}
+
+// Read the password change result headers, returning an error if the change was not successful.
+func passwordChangeHTTPError(resp *http.Response) error {
+	result := resp.Header.Get(passwordChangeResultHTTPHeader)
+	if resp.StatusCode == http.StatusOK && result == okPasswordChangeResult {
+		return nil
+	}
+
+	// Server errors from the web server will not have a result.
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return unexpectedHTTPError(resp)
+	}
+
+	var reason PasswordChangeReason
+	switch result {
+	case invalidPasswordPasswordChangeResult:
+		reason = PasswordChangeReasonInvalidPassword
+	case policyErrorPasswordChangeResult:
+		reason = PasswordChangeReasonPolicy
+	default:
+		reason = PasswordChangeReasonUnknown
+	}
+
+	return &PasswordChangeError{
+		Reason:      reason,
+		Result:      result,
+		PolicyError: resp.Header.Get(passwordChangePolicyHTTPHeader),
+		StatusCode:  resp.StatusCode,
 	}
-	return fmt.Errorf("unauthorized response <%s> (%d)", rejectionReason, errorCode)
 }
@@ -0,0 +1,40 @@
+package freeipa
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// Confirm errors from the API match both their code and the family the code belongs to.
+func TestErrorFamilies(t *testing.T) {
+	// A not found error should match itself and the execution family.
+	err := fmt.Errorf("wrapped: %w", &Error{Code: NotFoundCode, Name: "NotFound", Message: "user not found"})
+	if !errors.Is(err, ErrNotFound) || !errors.Is(err, ErrExecution) {
+		t.Errorf("expected not found to match its family: %s", err)
+	}
+	if errors.Is(err, ErrDuplicateEntry) || errors.Is(err, ErrAuthentication) {
+		t.Errorf("unexpected match for not found: %s", err)
+	}
+	if !IsNotFound(err) || IsDuplicate(err) {
+		t.Errorf("unexpected helper result for not found: %s", err)
+	}
+
+	// LDAP errors are a sub family of execution errors.
+	err = &Error{Code: DatabaseTimeoutCode}
+	if !errors.Is(err, ErrDatabaseTimeout) || !errors.Is(err, ErrLDAP) || !errors.Is(err, ErrExecution) {
+		t.Errorf("expected database timeout to match its families: %s", err)
+	}
+
+	// Codes without a specific sentinel fall back to their family by range.
+	err = &Error{Code: 4399}
+	if !errors.Is(err, ErrCertificate) || !errors.Is(err, ErrExecution) {
+		t.Errorf("expected unknown certificate code to match its family: %s", err)
+	}
+
+	// Authorization errors.
+	err = &Error{Code: ACIErrorCode}
+	if !IsAuthorization(err) || IsAuthentication(err) {
+		t.Errorf("expected aci error to be an authorization error: %s", err)
+	}
+}
@@ -0,0 +1,257 @@
+package freeipa
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Characters the criteria is extended with when results are truncated, which are the printable ASCII characters
+// other than upper case letters, as primary keys are matched without case.
+var shardCharacters = func() []rune {
+	var chars []rune
+	for c := ' '; c <= '~'; c++ {
+		if c < 'A' || c > 'Z' {
+			chars = append(chars, c)
+		}
+	}
+	return chars
+}()
+
+// Maximum length of the criteria before giving up on truncated results.
+const maxShardDepth = 64
+
+// Iterator over all entries matching a find command, working around the server's size limit.
+type FindIterator struct {
+	client *Client
+	ctx    context.Context
+	req    *Request
+	args   []interface{}
+	root   string
+
+	// Criteria yet to be searched, and those already queued.
+	queue  []string
+	queued map[string]bool
+
+	// Entries already returned by DN, and entries fetched but not yet returned.
+	seen    map[string]bool
+	entries []map[string]interface{}
+	entry   map[string]interface{}
+	err     error
+}
+
+// Iterate over every entry matching a find command, such as user_find, even when the server truncates results.
+// Truncated searches are split on the primary key, extending the criteria with each character the next key may
+// start with, and looking up the key equal to the criteria exactly, until no search is truncated.
+// Entries found by more than one search are only returned once. Keys with characters other than printable ASCII
+// are found when the server returns a key with the same prefix, otherwise the iterator cannot see them.
+// The criteria is the last positional argument, and may be left out for commands without other arguments.
+// As criteria also match attributes other than the primary key, truncated searches are only split without criteria;
+// use options to narrow the search instead, which are kept for each search.
+//
+//	it := client.FindAll(ctx, freeipa.NewRequest("user_find", nil, map[string]interface{}{"pkey_only": true}))
+//	for it.Next() {
+//		fmt.Println(it.Entry()["uid"])
+//	}
+//	if it.Err() != nil {
+//		...
+//	}
+func (c *Client) FindAll(ctx context.Context, req *Request) *FindIterator {
+	it := &FindIterator{
+		client: c,
+		ctx:    ctx,
+		req:    req,
+		queued: make(map[string]bool),
+		seen:   make(map[string]bool),
+	}
+	if !strings.HasSuffix(req.Method, "_find") {
+		it.err = fmt.Errorf("%s is not a find command", req.Method)
+		return it
+	}
+
+	// Take the criteria from the arguments, adding it if not provided.
+	if len(req.Params) > 0 {
+		it.args, _ = req.Params[0].([]interface{})
+	}
+	it.args = append([]interface{}(nil), it.args...)
+	if len(it.args) == 0 {
+		it.args = append(it.args, "")
+	}
+	root, ok := it.args[len(it.args)-1].(string)
+	if !ok {
+		it.err = fmt.Errorf("criteria for %s must be a string", req.Method)
+		return it
+	}
+	it.root = root
+	it.push(root)
+	return it
+}
+
+// Queue a search with the criteria, unless it was already queued.
+func (it *FindIterator) push(criteria string) {
+	if it.queued[criteria] {
+		return
+	}
+	it.queued[criteria] = true
+	it.queue = append(it.queue, criteria)
+}
+
+// Advance to the next entry, returning false when there are no more entries or an error occurred.
+func (it *FindIterator) Next() bool {
+	for len(it.entries) == 0 {
+		if it.err != nil || len(it.queue) == 0 {
+			it.entry = nil
+			return false
+		}
+		criteria := it.queue[0]
+		it.queue = it.queue[1:]
+		it.err = it.search(criteria)
+	}
+	it.entry = it.entries[0]
+	it.entries = it.entries[1:]
+	return true
+}
+
+// Get the current entry.
+func (it *FindIterator) Entry() map[string]interface{} {
+	return it.entry
+}
+
+// Decode the current entry into v, the same as Response.Decode.
+func (it *FindIterator) Decode(v interface{}) error {
+	if it.entry == nil {
+		return fmt.Errorf("no current entry")
+	}
+	return decodeInto(it.entry, v)
+}
+
+// Get the error which stopped iteration, if any.
+func (it *FindIterator) Err() error {
+	return it.err
+}
+
+// Send the find command with the criteria, adding the extra options to those of the request.
+func (it *FindIterator) find(criteria string, extra map[string]interface{}) (*Response, error) {
+	args := append([]interface{}(nil), it.args...)
+	args[len(args)-1] = criteria
+	var options interface{}
+	if len(it.req.Params) > 1 {
+		options = it.req.Params[1]
+	}
+	if len(extra) != 0 {
+		merged, err := encodeOptions(options)
+		if err != nil {
+			return nil, fmt.Errorf("error encoding options for %s: %w", it.req.Method, err)
+		}
+		for name, value := range extra {
+			merged[name] = value
+		}
+		options = merged
+	}
+	req := NewRequest(it.req.Method, args, options)
+	req.Idempotent = it.req.Idempotent
+	return it.client.DoContext(it.ctx, req)
+}
+
+// Keep the entries of a response which were not seen in other searches.
+func (it *FindIterator) keep(resp *Response) error {
+	results, _ := resp.Result.Result.([]interface{})
+	for _, result := range results {
+		entry, ok := result.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		key, ok := entry["dn"].(string)
+		if !ok {
+			// Without a DN, compare the whole entry.
+			data, err := json.Marshal(entry)
+			if err != nil {
+				return err
+			}
+			key = string(data)
+		}
+		if it.seen[key] {
+			continue
+		}
+		it.seen[key] = true
+		it.entries = append(it.entries, entry)
+	}
+	return nil
+}
+
+// Search with the criteria, keeping new entries and splitting the search if the results were truncated.
+func (it *FindIterator) search(criteria string) error {
+	resp, err := it.find(criteria, nil)
+	if err != nil {
+		return err
+	}
+	err = it.keep(resp)
+	if err != nil || !resp.Result.Truncated {
+		return err
+	}
+
+	// Criteria from the caller may match any attribute, so narrower criteria could miss entries.
+	if it.root != "" {
+		return fmt.Errorf("results of %s are truncated with criteria %q, which cannot be split without missing entries", it.req.Method, it.root)
+	}
+	if len(criteria) >= maxShardDepth {
+		return fmt.Errorf("results of %s are still truncated with criteria %q", it.req.Method, criteria)
+	}
+
+	// Every key starting with the criteria is found by extending it with the character which follows,
+	// including characters outside the usual set seen in the keys returned.
+	next := make(map[rune]bool)
+	for _, c := range shardCharacters {
+		next[c] = true
+	}
+	var pkey string
+	exact := false
+	results, _ := resp.Result.Result.([]interface{})
+	for _, result := range results {
+		entry, _ := result.(map[string]interface{})
+		attr, key, ok := primaryKey(entry)
+		if !ok {
+			return fmt.Errorf("results of %s are truncated and entries have no DN to split them by", it.req.Method)
+		}
+		pkey = attr
+		key = strings.ToLower(key)
+		if key == criteria {
+			exact = true
+		} else if strings.HasPrefix(key, criteria) {
+			c, _ := utf8.DecodeRuneInString(key[len(criteria):])
+			next[c] = true
+		}
+	}
+	chars := make([]rune, 0, len(next))
+	for c := range next {
+		chars = append(chars, c)
+	}
+	sort.Slice(chars, func(i, j int) bool { return chars[i] < chars[j] })
+	for _, c := range chars {
+		it.push(criteria + string(c))
+	}
+
+	// The key equal to the criteria is not found by extending it, so look it up exactly.
+	if criteria == "" || exact {
+		return nil
+	}
+	resp, err = it.find(criteria, map[string]interface{}{pkey: criteria})
+	if err != nil {
+		return err
+	}
+	return it.keep(resp)
+}
+
+// Get the primary key attribute and value of an entry from the first component of its DN.
+func primaryKey(entry map[string]interface{}) (string, string, bool) {
+	dn, _ := entry["dn"].(string)
+	rdn, _, _ := strings.Cut(dn, ",")
+	attr, value, ok := strings.Cut(rdn, "=")
+	if !ok || attr == "" {
+		return "", "", false
+	}
+	return strings.ToLower(attr), value, true
+}
@@ -0,0 +1,106 @@
+package freeipa
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Confirm all entries are found when the server truncates results.
+func TestFindAll(t *testing.T) {
+	// Make more users than the server returns at once, including keys which other keys start with
+	// and characters outside letters and digits.
+	users := []string{"userä"}
+	for i := 0; i < 40; i++ {
+		users = append(users, fmt.Sprintf("user%c%d", 'a'+i%5, i))
+	}
+	users = append(users, "admin", "johnny.bravo", "User$", "user b", "user")
+
+	searches := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ipa/session/login_password", handleLogin)
+	mux.HandleFunc("/ipa/session/json", func(w http.ResponseWriter, req *http.Request) {
+		res := new(Request)
+		json.NewDecoder(req.Body).Decode(res)
+		if res.Method != "user_find" {
+			sendInvalidJSON(w)
+			return
+		}
+		searches++
+
+		// Return up to 5 users containing the criteria without case, and equal to the uid option if set.
+		criteria := res.Params[0].([]interface{})[0].(string)
+		exact, _ := res.Params[1].(map[string]interface{})["uid"].(string)
+		var entries []string
+		truncated := false
+		for _, uid := range users {
+			if !strings.Contains(strings.ToLower(uid), criteria) || exact != "" && !strings.EqualFold(uid, exact) {
+				continue
+			}
+			if len(entries) == 5 {
+				truncated = true
+				break
+			}
+			entries = append(entries, fmt.Sprintf(`{"dn": "uid=%s,cn=users,cn=accounts,dc=example,dc=com", "uid": ["%s"]}`, uid, uid))
+		}
+		fmt.Fprintf(w, `{"result": {"count": %d, "truncated": %t, "result": [%s]}, "version": "4.9.8", "error": null, "id": null, "principal": "test@EXAMPLE.COM"}`, len(entries), truncated, strings.Join(entries, ","))
+	})
+	srv := httptest.NewTLSServer(mux)
+	defer srv.Close()
+
+	client, err := NewClient(
+		strings.TrimPrefix(srv.URL, "https://"),
+		WithTransport(srv.Client().Transport.(*http.Transport)),
+		WithAuthenticator(&PasswordAuthenticator{User: "test", Password: "testpassword"}),
+		WithAPIVersion("2.245"),
+	)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+
+	// Each user should be found once.
+	found := make(map[string]int)
+	it := client.FindAll(context.Background(), NewRequest("user_find", nil, map[string]interface{}{"pkey_only": true}))
+	for it.Next() {
+		var user struct {
+			UID string `ipa:"uid"`
+		}
+		err = it.Decode(&user)
+		if err != nil {
+			t.Fatalf("error: %s", err)
+		}
+		found[user.UID]++
+	}
+	if it.Err() != nil {
+		t.Fatalf("error: %s", it.Err())
+	}
+	if len(found) != len(users) {
+		t.Errorf("expected %d users: %d", len(users), len(found))
+	}
+	for uid, count := range found {
+		if count != 1 {
+			t.Errorf("user %s found %d times", uid, count)
+		}
+	}
+	if searches < 2 {
+		t.Errorf("expected truncated search to be split: %d", searches)
+	}
+
+	// Criteria may match other attributes, so truncated searches with criteria are not split.
+	it = client.FindAll(context.Background(), NewRequest("user_find", []interface{}{"user"}, nil))
+	for it.Next() {
+	}
+	if it.Err() == nil {
+		t.Errorf("expected error for truncated search with criteria")
+	}
+
+	// Only find commands may be iterated.
+	it = client.FindAll(context.Background(), NewRequest("user_show", []interface{}{"admin"}, nil))
+	if it.Next() || it.Err() == nil {
+		t.Errorf("expected error for non-find command")
+	}
+}
@@ -0,0 +1,296 @@
+package freeipa
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	krb5client "github.com/jcmturner/gokrb5/v8/client"
+	krb5config "github.com/jcmturner/gokrb5/v8/config"
+	"github.com/jcmturner/gokrb5/v8/credentials"
+	"github.com/jcmturner/gokrb5/v8/keytab"
+	"github.com/jcmturner/gokrb5/v8/spnego"
+)
+
+// Options for connecting to Kerberos.
+// Credentials are used in order of keytab, then password, falling back to a credential cache from a prior kinit.
+type KerberosConnectOptions struct {
+	// Kerberos configuration, defaults to the file in KRB5_CONFIG or /etc/krb5.conf if not provided.
+	Krb5ConfigReader io.Reader
+	KeytabReader     io.Reader
+	// Password to obtain a ticket with when no keytab is provided.
+	Password string
+	// Credential cache to use when no keytab or password is provided. If neither is provided, the cache in KRB5CCNAME,
+	// then default_ccache_name in the Kerberos configuration, then the default cache location is used.
+	CCacheReader io.Reader
+	CCachePath   string
+	User         string
+	Realm        string
+}
+
+// Default locations of Kerberos files.
+const (
+	defaultKrb5ConfigPath   = "/etc/krb5.conf"
+	defaultCCachePathFormat = "/tmp/krb5cc_%d"
+)
+
+// Create a new client using Kerberos authentication.
+func ConnectWithKerberos(host string, transport *http.Transport, options *KerberosConnectOptions) (*Client, error) {
+	return ConnectWithKerberosContext(context.Background(), host, transport, options)
+}
+
+// Create a new client using Kerberos authentication, with the login bound to the context.
+func ConnectWithKerberosContext(ctx context.Context, host string, transport *http.Transport, options *KerberosConnectOptions) (*Client, error) {
+	// Setup kerberos authentication with the provided credentials.
+	auth, err := NewKerberosAuthenticator(options)
+	if err != nil {
+		return nil, err
+	}
+	return NewClientContext(ctx, host, WithTransport(transport), WithAuthenticator(auth))
+}
+
+// Authenticate using a Kerberos client with SPNEGO.
+type KerberosAuthenticator struct {
+	Client *krb5client.Client
+}
+
+// Make a Kerberos authenticator from the credentials provided in the options.
+func NewKerberosAuthenticator(options *KerberosConnectOptions) (*KerberosAuthenticator, error) {
+	krb5, err := newKrb5Client(options)
+	if err != nil {
+		return nil, err
+	}
+	return &KerberosAuthenticator{Client: krb5}, nil
+}
+
+// Make a Kerberos authenticator using a keytab.
+func NewKeytabAuthenticator(krb5Config, keytab io.Reader, user, realm string) (*KerberosAuthenticator, error) {
+	return NewKerberosAuthenticator(&KerberosConnectOptions{
+		Krb5ConfigReader: krb5Config,
+		KeytabReader:     keytab,
+		User:             user,
+		Realm:            realm,
+	})
+}
+
+// Make a Kerberos authenticator using a credential cache, the default cache is used if the path is empty.
+func NewCCacheAuthenticator(krb5Config io.Reader, ccachePath string) (*KerberosAuthenticator, error) {
+	return NewKerberosAuthenticator(&KerberosConnectOptions{
+		Krb5ConfigReader: krb5Config,
+		CCachePath:       ccachePath,
+	})
+}
+
+// Read the kerberos configuration from the options, or the default configuration file.
+// The configured default credential cache name is also returned, as the kerberos library does not keep it.
+func loadKrb5Config(options *KerberosConnectOptions) (*krb5config.Config, string, error) {
+	var data []byte
+	var err error
+	if options.Krb5ConfigReader != nil {
+		data, err = io.ReadAll(options.Krb5ConfigReader)
+	} else {
+		path := os.Getenv("KRB5_CONFIG")
+		if path == "" {
+			path = defaultKrb5ConfigPath
+		}
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	config, err := krb5config.NewFromString(string(data))
+	if err != nil {
+		return nil, "", err
+	}
+	return config, configuredCCacheName(string(data)), nil
+}
+
+// Find default_ccache_name in the libdefaults section of the kerberos configuration.
+func configuredCCacheName(config string) string {
+	section := ""
+	depth := 0
+	for _, line := range strings.Split(config, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			depth = 0
+			continue
+		}
+
+		// Skip values in sub-sections, which are for specific realms.
+		if strings.HasSuffix(line, "{") {
+			depth++
+			continue
+		}
+		if line == "}" {
+			depth--
+			continue
+		}
+		if section != "libdefaults" || depth > 0 {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if ok && strings.TrimSpace(key) == "default_ccache_name" {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
+
+// Expand the parameters MIT Kerberos allows in credential cache names.
+func expandCCacheName(name string) string {
+	uid := strconv.Itoa(os.Getuid())
+	temp := os.Getenv("TMPDIR")
+	if temp == "" {
+		temp = "/tmp"
+	}
+	return strings.NewReplacer(
+		"%{uid}", uid,
+		"%{USERID}", uid,
+		"%{euid}", strconv.Itoa(os.Geteuid()),
+		"%{TEMP}", temp,
+	).Replace(name)
+}
+
+// Find the credential cache path, using the same lookup order as the MIT Kerberos tools:
+// KRB5CCNAME, then the configured default name, then the default location for the user.
+func defaultCCachePath(configured string) (string, error) {
+	name := os.Getenv("KRB5CCNAME")
+	if name == "" {
+		name = expandCCacheName(configured)
+	}
+	if name == "" {
+		return fmt.Sprintf(defaultCCachePathFormat, os.Getuid()), nil
+	}
+
+	// Only file based caches can be read, other types live in the kernel or a daemon.
+	if strings.HasPrefix(name, "FILE:") {
+		return strings.TrimPrefix(name, "FILE:"), nil
+	}
+	if i := strings.Index(name, ":"); i > 0 && !strings.HasPrefix(name, "/") {
+		return "", fmt.Errorf("unsupported credential cache type: %s", name[:i])
+	}
+	return name, nil
+}
+
+// Read the credential cache from the options, or the default cache location.
+func loadCCache(options *KerberosConnectOptions, configured string) (*credentials.CCache, error) {
+	if options.CCacheReader != nil {
+		data, err := io.ReadAll(options.CCacheReader)
+		if err != nil {
+			return nil, err
+		}
+		ccache := new(credentials.CCache)
+		err = ccache.Unmarshal(data)
+		if err != nil {
+			return nil, err
+		}
+		return ccache, nil
+	}
+
+	path := options.CCachePath
+	if path == "" {
+		var err error
+		path, err = defaultCCachePath(configured)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return credentials.LoadCCache(path)
+}
+
+// Setup the kerberos client from the credentials provided in the options.
+func newKrb5Client(options *KerberosConnectOptions) (*krb5client.Client, error) {
+	// Read the kerberos configuration file for server connection information.
+	krb5Config, ccacheName, err := loadKrb5Config(options)
+	if err != nil {
+		return nil, fmt.Errorf("error reading kerberos configuration: %s", err)
+	}
+
+	// Without a keytab, obtain tickets using the password if provided.
+	if options.KeytabReader == nil && options.Password != "" {
+		return krb5client.NewWithPassword(options.User, options.Realm, options.Password, krb5Config), nil
+	}
+
+	// Without a keytab or password, use the credential cache.
+	if options.KeytabReader == nil {
+		ccache, err := loadCCache(options, ccacheName)
+		if err != nil {
+			return nil, fmt.Errorf("error reading credential cache: %s", err)
+		}
+
+		// Setup kerberos client with the credential cache and config.
+		krb5, err := krb5client.NewFromCCache(ccache, krb5Config)
+		if err != nil {
+			return nil, fmt.Errorf("error loading credential cache: %s", err)
+		}
+		return krb5, nil
+	}
+
+	// Read the keytab data.
+	ktData, err := io.ReadAll(options.KeytabReader)
+	if err != nil {
+		return nil, fmt.Errorf("error reading keytab: %s", err)
+	}
+
+	// Parse the keytab data.
+	kt := keytab.New()
+	err = kt.Unmarshal(ktData)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing keytab: %s", err)
+	}
+
+	// Setup kerberos client with keytab and config.
+	return krb5client.NewWithKeytab(options.User, options.Realm, kt, krb5Config), nil
+}
+
+// Get the principal logged in as.
+func (a *KerberosAuthenticator) identity() string {
+	if a.Client == nil || a.Client.Credentials == nil {
+		return ""
+	}
+	return a.Client.Credentials.UserName() + "@" + a.Client.Credentials.Domain()
+}
+
+// Login using the kerberos client.
+func (a *KerberosAuthenticator) Login(ctx context.Context, client *http.Client, baseURL string) error {
+	// Acquiring tickets is not context aware, so stop before starting the handshake if already canceled.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// Wrapper for authenticating with Kerberos credentials.
+	spnegoCl := spnego.NewClient(a.Client, client, "")
+
+	// Setup request for authenticate.
+	req, err := http.NewRequestWithContext(ctx, "POST", baseURL+"/session/login_kerberos", nil)
+	if err != nil {
+		return fmt.Errorf("error building login request: %s", err)
+	}
+	req.Header.Add("Referer", baseURL)
+
+	// Perform authenticate using Kerberos.
+	res, err := spnegoCl.Do(req)
+	if err != nil {
+		return fmt.Errorf("error logging in using Kerberos: %w", err)
+	}
+	defer res.Body.Close()
+
+	// If an error occurs, provide details if possible on why.
+	if res.StatusCode != http.StatusOK {
+		if res.StatusCode == http.StatusUnauthorized {
+			return unauthorizedHTTPError(res)
+		}
+		return unexpectedHTTPError(res)
+	}
+
+	// Successful authentication.
+	return nil
+}
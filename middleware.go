@@ -0,0 +1,257 @@
+package freeipa
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// Function which sends a prepared request and returns the response, as wrapped by middleware.
+// The request has its parameters encoded and the API version added.
+type RoundTrip func(ctx context.Context, req *Request) (*Response, error)
+
+// Middleware wraps the round trip of each request made with Do, DoContext, DoBatch and the generated commands,
+// and may inspect or change the request and response, or return without calling the next round trip.
+// Logins, keep-alive pings and schema requests made by the client itself do not pass through middleware.
+type Middleware func(next RoundTrip) RoundTrip
+
+// Build the round trip through the middleware, with the first middleware outermost.
+func chainMiddleware(rt RoundTrip, middleware []Middleware) RoundTrip {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		rt = middleware[i](rt)
+	}
+	return rt
+}
+
+// Check if the request makes changes, including batches containing commands which make changes.
+func isMutatingRequest(req *Request) bool {
+	if req.Method == "batch" {
+		for _, command := range batchCommands(req) {
+			if isMutatingRequest(command) {
+				return true
+			}
+		}
+		return false
+	}
+	return !isReadOnlyCommand(req.Method)
+}
+
+// Get the commands of a prepared batch request.
+func batchCommands(req *Request) []*Request {
+	if len(req.Params) == 0 {
+		return nil
+	}
+	args, _ := req.Params[0].([]interface{})
+	var commands []*Request
+	for _, arg := range args {
+		if command, ok := arg.(*Request); ok {
+			commands = append(commands, command)
+		}
+	}
+	return commands
+}
+
+// Log each request with the server which handled it and how long it took, using the standard logger if nil.
+// Parameters are not logged, as they may contain passwords.
+func LoggingMiddleware(logger *log.Logger) Middleware {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return func(next RoundTrip) RoundTrip {
+		return func(ctx context.Context, req *Request) (*Response, error) {
+			start := time.Now()
+			resp, err := next(ctx, req)
+			elapsed := time.Since(start)
+			// Middleware may return without a response, so there is no server to log.
+			if err != nil {
+				logger.Printf("freeipa: %s failed after %s: %s", req.Method, elapsed, err)
+			} else if resp == nil {
+				logger.Printf("freeipa: %s took %s", req.Method, elapsed)
+			} else {
+				logger.Printf("freeipa: %s on %s took %s", req.Method, resp.Server, elapsed)
+			}
+			return resp, err
+		}
+	}
+}
+
+// Report the duration and error of each request to the observer, such as a function updating metrics counters and histograms.
+func MetricsMiddleware(observe func(method string, duration time.Duration, err error)) Middleware {
+	return func(next RoundTrip) RoundTrip {
+		return func(ctx context.Context, req *Request) (*Response, error) {
+			start := time.Now()
+			resp, err := next(ctx, req)
+			observe(req.Method, time.Since(start), err)
+			return resp, err
+		}
+	}
+}
+
+// Context key marking requests retried by RetryMiddleware.
+type retryMiddlewareKey struct{}
+
+// Retry requests which fail with transient errors using the policy. Unlike WithRetryPolicy, the retries pass through
+// the middleware after this one, so each attempt is seen by them. The client's own retry policy is not used
+// for requests passing through this middleware, so requests are not retried twice.
+func RetryMiddleware(policy RetryPolicy) Middleware {
+	return func(next RoundTrip) RoundTrip {
+		return func(ctx context.Context, req *Request) (*Response, error) {
+			ctx = context.WithValue(ctx, retryMiddlewareKey{}, true)
+			return policy.do(ctx, req, func() (*Response, error) {
+				return next(ctx, req)
+			})
+		}
+	}
+}
+
+// Summary of responses to requests skipped by the dry-run middleware.
+const dryRunSummary = "Dry run, request not sent"
+
+// Skip sending requests which make changes, returning an empty result in their place.
+// Read-only commands such as find and show are still sent, and batches are skipped if any command makes changes.
+// Combined with WithValidation, requests are still checked against the schema.
+func DryRunMiddleware() Middleware {
+	return func(next RoundTrip) RoundTrip {
+		return func(ctx context.Context, req *Request) (*Response, error) {
+			if !isMutatingRequest(req) {
+				return next(ctx, req)
+			}
+			result := &Result{Summary: dryRunSummary}
+
+			// Batches have an empty result for each command.
+			if req.Method == "batch" {
+				commands := batchCommands(req)
+				result.Count = len(commands)
+				for range commands {
+					result.Results = append(result.Results, json.RawMessage(`{"result": null, "summary": "`+dryRunSummary+`", "error": null}`))
+				}
+			}
+			return &Response{Result: result}, nil
+		}
+	}
+}
+
+// Record of a command which makes changes, as provided to the audit middleware.
+type AuditEntry struct {
+	Time     time.Time
+	Method   string
+	Args     []interface{}
+	Options  map[string]interface{}
+	Server   string
+	Duration time.Duration
+	// Error of the command, nil if it succeeded.
+	Err error
+}
+
+// Parts of option names whose values are hidden in audit entries.
+var sensitiveOptions = []string{"password", "passwd", "secret", "passphrase", "private", "otpkey", "principalkey"}
+
+// Positions of arguments whose values are hidden in audit entries, by command.
+var sensitiveArgs = map[string][]int{
+	// The new and current passwords, after the principal.
+	"passwd": {1, 2},
+}
+
+// Replacement for hidden option and argument values.
+const redactedValue = "********"
+
+// Record each command which makes changes after it is sent, whether it succeeded or failed.
+// Commands in a batch are recorded individually, each with its own error, or the error of the batch if it failed.
+// Values of options and arguments which may be secrets, such as passwords and keys, are hidden.
+func AuditMiddleware(record func(ctx context.Context, entry AuditEntry)) Middleware {
+	return func(next RoundTrip) RoundTrip {
+		return func(ctx context.Context, req *Request) (*Response, error) {
+			if !isMutatingRequest(req) {
+				return next(ctx, req)
+			}
+			start := time.Now()
+			resp, err := next(ctx, req)
+			entry := AuditEntry{
+				Time:     start,
+				Duration: time.Since(start),
+				Err:      err,
+			}
+			if resp != nil {
+				entry.Server = resp.Server
+			}
+
+			// Record each command which makes changes.
+			commands := []*Request{req}
+			if req.Method == "batch" {
+				commands = batchCommands(req)
+			}
+			for i, command := range commands {
+				if !isMutatingRequest(command) {
+					continue
+				}
+				entry.Method = command.Method
+				entry.Args, entry.Options = auditParams(command)
+				if req.Method == "batch" && err == nil {
+					entry.Err = batchResultError(resp, i)
+				}
+				record(ctx, entry)
+			}
+			return resp, err
+		}
+	}
+}
+
+// Get the error of a command in a batch response, or nil if it succeeded.
+func batchResultError(resp *Response, i int) error {
+	if resp == nil || resp.Result == nil || i >= len(resp.Result.Results) {
+		return fmt.Errorf("batch returned no result for command %d", i)
+	}
+	item := new(batchItem)
+	err := json.Unmarshal(resp.Result.Results[i], item)
+	if err != nil {
+		return err
+	}
+	return item.err()
+}
+
+// Get the arguments and options of a prepared request for an audit entry, hiding sensitive values and the API version.
+func auditParams(req *Request) ([]interface{}, map[string]interface{}) {
+	var args []interface{}
+	var options map[string]interface{}
+	if len(req.Params) > 0 {
+		args, _ = req.Params[0].([]interface{})
+	}
+
+	// Copy the arguments before hiding any, so the request is not changed.
+	if positions := sensitiveArgs[req.Method]; len(positions) != 0 {
+		args = append([]interface{}(nil), args...)
+		for _, i := range positions {
+			if i < len(args) {
+				args[i] = redactedValue
+			}
+		}
+	}
+	if len(req.Params) > 1 {
+		params, _ := req.Params[1].(map[string]interface{})
+		options = make(map[string]interface{}, len(params))
+		for name, value := range params {
+			if name == "version" {
+				continue
+			}
+			if isSensitiveOption(name) {
+				value = redactedValue
+			}
+			options[name] = value
+		}
+	}
+	return args, options
+}
+
+// Check if the option may hold a secret.
+func isSensitiveOption(name string) bool {
+	name = strings.ToLower(name)
+	for _, part := range sensitiveOptions {
+		if strings.Contains(name, part) {
+			return true
+		}
+	}
+	return false
+}
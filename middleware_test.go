@@ -0,0 +1,206 @@
+package freeipa
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Confirm middleware wraps requests in order, and the built-in middleware log, measure, retry, skip and audit requests.
+func TestMiddleware(t *testing.T) {
+	var methods []string
+	failures := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ipa/session/login_password", handleLogin)
+	mux.HandleFunc("/ipa/session/json", func(w http.ResponseWriter, req *http.Request) {
+		res := new(Request)
+		json.NewDecoder(req.Body).Decode(res)
+		methods = append(methods, res.Method)
+		if failures > 0 {
+			failures--
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			return
+		}
+		switch res.Method {
+		case "batch":
+			fmt.Fprintf(w, `{"result": {"count": 2, "results": [{"result": {"uid": ["admin"]}, "value": "admin", "error": null}, {"result": null, "error": "jdoe: user not found", "error_code": 4001, "error_name": "NotFound", "error_kw": {"reason": "jdoe: user not found"}}]}, "version": "4.9.8", "error": null, "id": null, "principal": "test@EXAMPLE.COM"}`)
+		default:
+			fmt.Fprintf(w, `{"result": {"summary": null, "result": {"uid": ["admin"]}, "value": "admin"}, "version": "4.9.8", "error": null, "id": null, "principal": "test@EXAMPLE.COM"}`)
+		}
+	})
+	srv := httptest.NewTLSServer(mux)
+	defer srv.Close()
+
+	// Note the order middleware is called in, and let a middleware change the request.
+	var order []string
+	trace := func(name string) Middleware {
+		return func(next RoundTrip) RoundTrip {
+			return func(ctx context.Context, req *Request) (*Response, error) {
+				order = append(order, name)
+				return next(ctx, req)
+			}
+		}
+	}
+	var logs bytes.Buffer
+	var observed []string
+	var audit []AuditEntry
+	connect := func(middleware ...Middleware) *Client {
+		client, err := NewClient(
+			strings.TrimPrefix(srv.URL, "https://"),
+			WithTransport(srv.Client().Transport.(*http.Transport)),
+			WithAuthenticator(&PasswordAuthenticator{User: "test", Password: "testpassword"}),
+			WithAPIVersion("2.245"),
+			WithRetryPolicy(RetryPolicy{MaxAttempts: 1}),
+			WithMiddleware(middleware...),
+		)
+		if err != nil {
+			t.Fatalf("error: %s", err)
+		}
+		return client
+	}
+	client := connect(
+		trace("outer"),
+		trace("inner"),
+		LoggingMiddleware(log.New(&logs, "", 0)),
+		RetryMiddleware(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}),
+		MetricsMiddleware(func(method string, duration time.Duration, err error) {
+			observed = append(observed, fmt.Sprintf("%s %v", method, err == nil))
+		}),
+		AuditMiddleware(func(ctx context.Context, entry AuditEntry) {
+			audit = append(audit, entry)
+		}),
+	)
+
+	// Read-only requests pass through each middleware in order, with retries seen by the middleware after the retry.
+	failures = 1
+	_, err := client.Do(NewRequest("user_show", []interface{}{"admin"}, nil))
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	if strings.Join(order, ",") != "outer,inner" {
+		t.Errorf("unexpected middleware order: %v", order)
+	}
+	if len(observed) != 2 || observed[0] != "user_show false" || observed[1] != "user_show true" {
+		t.Errorf("unexpected metrics: %v", observed)
+	}
+	if strings.Count(logs.String(), "\n") != 1 || !strings.Contains(logs.String(), "user_show on ") {
+		t.Errorf("unexpected logs: %q", logs.String())
+	}
+	if len(audit) != 0 {
+		t.Errorf("read-only request was audited: %v", audit)
+	}
+
+	// Commands which make changes are audited with sensitive options hidden.
+	_, err = client.Do(NewRequest("user_mod", []interface{}{"admin"}, map[string]interface{}{"userpassword": "secret", "givenname": "Admin"}))
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	if len(audit) != 1 {
+		t.Fatalf("expected audit entry: %v", audit)
+	}
+	entry := audit[0]
+	if entry.Method != "user_mod" || entry.Args[0] != "admin" || entry.Options["userpassword"] != redactedValue || entry.Options["givenname"] != "Admin" || entry.Err != nil || entry.Server == "" {
+		t.Errorf("unexpected audit entry: %+v", entry)
+	}
+	if _, ok := entry.Options["version"]; ok {
+		t.Errorf("version was audited")
+	}
+
+	// Sensitive arguments are hidden without changing the request.
+	audit = nil
+	req := NewRequest("passwd", []interface{}{"admin", "newpassword", "oldpassword"}, nil)
+	_, err = client.Do(req)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	if len(audit) != 1 || audit[0].Args[0] != "admin" || audit[0].Args[1] != redactedValue || audit[0].Args[2] != redactedValue {
+		t.Errorf("unexpected passwd audit: %+v", audit)
+	}
+	if req.Params[0].([]interface{})[1] != "newpassword" {
+		t.Errorf("request was changed by audit")
+	}
+
+	// Commands in a batch are audited individually with their own errors.
+	audit = nil
+	_, err = client.DoBatch(context.Background(), NewBatch(
+		NewRequest("user_show", []interface{}{"admin"}, nil),
+		NewRequest("user_disable", []interface{}{"jdoe"}, nil),
+	))
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	if len(audit) != 1 || audit[0].Method != "user_disable" || !errors.Is(audit[0].Err, ErrNotFound) {
+		t.Errorf("unexpected batch audit: %+v", audit)
+	}
+
+	// The client's retry policy is not used for requests retried by the middleware.
+	retrying, err := NewClient(
+		strings.TrimPrefix(srv.URL, "https://"),
+		WithTransport(srv.Client().Transport.(*http.Transport)),
+		WithAuthenticator(&PasswordAuthenticator{User: "test", Password: "testpassword"}),
+		WithAPIVersion("2.245"),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}),
+		WithMiddleware(RetryMiddleware(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond})),
+	)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	methods = nil
+	failures = 10
+	_, err = retrying.Do(NewRequest("user_show", []interface{}{"admin"}, nil))
+	failures = 0
+	if err == nil || len(methods) != 3 {
+		t.Errorf("expected three attempts: %v %v", methods, err)
+	}
+
+	// A dry run skips commands which make changes, but still sends read-only commands.
+	client = connect(DryRunMiddleware())
+	methods = nil
+	resp, err := client.Do(NewRequest("user_del", []interface{}{"jdoe"}, nil))
+	if err != nil || resp.Result.Summary != dryRunSummary {
+		t.Errorf("unexpected dry run: %v %v", resp, err)
+	}
+	results, err := client.DoBatch(context.Background(), NewBatch(
+		NewRequest("user_show", []interface{}{"admin"}, nil),
+		NewRequest("user_disable", []interface{}{"jdoe"}, nil),
+	))
+	if err != nil || len(results) != 2 || results[1].Err != nil || results[1].Response.Result.Summary != dryRunSummary {
+		t.Errorf("unexpected dry run batch: %+v %v", results, err)
+	}
+	_, err = client.Do(NewRequest("user_show", []interface{}{"admin"}, nil))
+	if err != nil || len(methods) != 1 || methods[0] != "user_show" {
+		t.Errorf("expected only read-only request to be sent: %v %v", methods, err)
+	}
+
+	// Middleware which returns without a response does not break the middleware before it.
+	empty := func(next RoundTrip) RoundTrip {
+		return func(ctx context.Context, req *Request) (*Response, error) {
+			return nil, nil
+		}
+	}
+	logs.Reset()
+	audit = nil
+	client = connect(
+		LoggingMiddleware(log.New(&logs, "", 0)),
+		AuditMiddleware(func(ctx context.Context, entry AuditEntry) {
+			audit = append(audit, entry)
+		}),
+		empty,
+	)
+	_, err = client.Do(NewRequest("user_del", []interface{}{"jdoe"}, nil))
+	if err == nil || !strings.Contains(logs.String(), "user_del took ") {
+		t.Errorf("unexpected log without response: %v %q", err, logs.String())
+	}
+	_, err = client.DoBatch(context.Background(), NewBatch(NewRequest("user_disable", []interface{}{"jdoe"}, nil)))
+	if err == nil || len(audit) != 2 || audit[0].Err != nil || audit[0].Server != "" || audit[1].Err == nil {
+		t.Errorf("unexpected audit without response: %+v", audit)
+	}
+}
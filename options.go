@@ -0,0 +1,342 @@
+package freeipa
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"os"
+	"time"
+)
+
+// Defaults used when making a new client.
+const (
+	DefaultCAFile   = "/etc/ipa/ca.crt"
+	DefaultBasePath = "/ipa"
+)
+
+// Configuration applied by options when making a new client.
+type clientOptions struct {
+	transport  *http.Transport
+	httpClient *http.Client
+	auth       Authenticator
+	caPEM      [][]byte
+	timeout    time.Duration
+	userAgent  string
+	basePath   string
+	servers    []string
+	policy     ServerPolicy
+	apiVersion string
+	validate   bool
+	retry      RetryPolicy
+	lifetime   time.Duration
+	keepAlive  time.Duration
+	store      SessionStore
+	middleware []Middleware
+}
+
+// Option for configuring a new client.
+type Option func(*clientOptions) error
+
+// Apply options over the defaults.
+func newClientOptions(opts []Option) (*clientOptions, error) {
+	options := &clientOptions{
+		basePath: DefaultBasePath,
+		retry:    DefaultRetryPolicy(),
+		lifetime: DefaultSessionLifetime,
+	}
+	for _, opt := range opts {
+		err := opt(options)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return options, nil
+}
+
+// Use the provided transport for connections to FreeIPA.
+func WithTransport(transport *http.Transport) Option {
+	return func(o *clientOptions) error {
+		o.transport = transport
+		return nil
+	}
+}
+
+// Use the provided http client for connections to FreeIPA.
+// The client is copied, and a cookie jar is added if it does not have one.
+// CAs cannot be added to the client's transport, so this cannot be combined with WithCAFile or WithCAPEM.
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *clientOptions) error {
+		o.httpClient = client
+		return nil
+	}
+}
+
+// Use the provided authenticator to login, and to re-login when the session expires.
+func WithAuthenticator(auth Authenticator) Option {
+	return func(o *clientOptions) error {
+		o.auth = auth
+		return nil
+	}
+}
+
+// Trust the CA certificates in the PEM file, such as the IPA CA at /etc/ipa/ca.crt.
+func WithCAFile(path string) Option {
+	return func(o *clientOptions) error {
+		pem, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("error reading CA file: %s", err)
+		}
+		o.caPEM = append(o.caPEM, pem)
+		return nil
+	}
+}
+
+// Trust the PEM encoded CA certificates.
+func WithCAPEM(pem []byte) Option {
+	return func(o *clientOptions) error {
+		o.caPEM = append(o.caPEM, pem)
+		return nil
+	}
+}
+
+// Limit the time each http request may take, including reading the response.
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *clientOptions) error {
+		o.timeout = timeout
+		return nil
+	}
+}
+
+// Send the provided user agent with each request.
+func WithUserAgent(userAgent string) Option {
+	return func(o *clientOptions) error {
+		o.userAgent = userAgent
+		return nil
+	}
+}
+
+// Use a different path to the FreeIPA API than /ipa, such as when behind a reverse proxy.
+func WithBasePath(basePath string) Option {
+	return func(o *clientOptions) error {
+		o.basePath = basePath
+		return nil
+	}
+}
+
+// Add servers to fail over to when the previous servers are unavailable.
+func WithServers(hosts ...string) Option {
+	return func(o *clientOptions) error {
+		o.servers = append(o.servers, hosts...)
+		return nil
+	}
+}
+
+// Choose the order servers are tried in, defaulting to the order they were provided.
+func WithServerPolicy(policy ServerPolicy) Option {
+	return func(o *clientOptions) error {
+		o.policy = policy
+		return nil
+	}
+}
+
+// Use the provided API version instead of negotiating it with the server.
+func WithAPIVersion(version string) Option {
+	return func(o *clientOptions) error {
+		o.apiVersion = version
+		return nil
+	}
+}
+
+// Validate requests against the API schema before sending them, returning the errors the server would.
+// The schema is fetched from the server when first needed, and cached until it changes.
+func WithValidation() Option {
+	return func(o *clientOptions) error {
+		o.validate = true
+		return nil
+	}
+}
+
+// Use the retry policy for transient failures instead of the default, a policy with one attempt disables retries.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(o *clientOptions) error {
+		o.retry = policy
+		return nil
+	}
+}
+
+// Assume sessions last this long when the session cookie does not say when it expires.
+// The client logs in again shortly before a session expires.
+func WithSessionLifetime(lifetime time.Duration) Option {
+	return func(o *clientOptions) error {
+		o.lifetime = lifetime
+		return nil
+	}
+}
+
+// Ping each server with a session at the interval in the background, keeping sessions alive while idle
+// and renewing them before they expire. The background goroutine is stopped by Client.Close.
+func WithKeepAlive(interval time.Duration) Option {
+	return func(o *clientOptions) error {
+		if interval <= 0 {
+			return errors.New("keep-alive interval must be positive")
+		}
+		o.keepAlive = interval
+		return nil
+	}
+}
+
+// Save sessions to the store after logging in, and reuse them when a new client is made if they are still valid.
+func WithSessionStore(store SessionStore) Option {
+	return func(o *clientOptions) error {
+		o.store = store
+		return nil
+	}
+}
+
+// Wrap requests with the middleware, with the first middleware outermost. May be used more than once to add more middleware.
+func WithMiddleware(middleware ...Middleware) Option {
+	return func(o *clientOptions) error {
+		o.middleware = append(o.middleware, middleware...)
+		return nil
+	}
+}
+
+// Build a pool of the system CAs with the configured CAs added.
+// If no CAs are configured, the IPA CA is added if this is an enrolled machine.
+func (o *clientOptions) certPool() (*x509.CertPool, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+
+	caPEM := o.caPEM
+	if len(caPEM) == 0 {
+		pem, err := os.ReadFile(DefaultCAFile)
+		if err == nil {
+			caPEM = append(caPEM, pem)
+		}
+	}
+	for _, pem := range caPEM {
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no CA certificates found in PEM")
+		}
+	}
+	return pool, nil
+}
+
+// Get the transport to use, building one that trusts the IPA CA if none was provided.
+func (o *clientOptions) buildTransport() (*http.Transport, error) {
+	// Use the provided transport, only changing it if CAs were configured.
+	if o.transport != nil && len(o.caPEM) == 0 {
+		return o.transport, nil
+	}
+
+	// Setup trusted CAs.
+	pool, err := o.certPool()
+	if err != nil {
+		return nil, err
+	}
+
+	// Copy the provided transport to add the CAs without modifying it.
+	if o.transport != nil {
+		transport := o.transport.Clone()
+		if transport.TLSClientConfig == nil {
+			transport.TLSClientConfig = &tls.Config{}
+		}
+		transport.TLSClientConfig.RootCAs = pool
+		return transport, nil
+	}
+
+	// Build a transport with secure TLS defaults.
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		RootCAs:    pool,
+	}
+	return transport, nil
+}
+
+// Setup the http client from the options, with a cookie jar to store FreeIPA session cookies.
+func (o *clientOptions) httpClientFor(auth Authenticator) (*http.Client, error) {
+	client := new(http.Client)
+	if o.httpClient != nil {
+		// The provided client's transport is used as is, so configured CAs would be ignored.
+		if len(o.caPEM) != 0 {
+			return nil, errors.New("CAs cannot be configured with a provided http client, configure them in its transport")
+		}
+		*client = *o.httpClient
+	} else {
+		transport, err := o.buildTransport()
+		if err != nil {
+			return nil, err
+		}
+		client.Transport = transport
+	}
+
+	// Allow the authenticator to configure the transport, such as adding a client certificate.
+	if configurer, ok := auth.(transportConfigurer); ok {
+		var transport *http.Transport
+		if client.Transport != nil {
+			transport, ok = client.Transport.(*http.Transport)
+			if !ok {
+				return nil, errors.New("authenticator requires an *http.Transport")
+			}
+		}
+		client.Transport = configurer.configureTransport(transport)
+	}
+
+	// Send the user agent with each request.
+	if o.userAgent != "" {
+		client.Transport = &userAgentTransport{
+			userAgent: o.userAgent,
+			next:      client.Transport,
+		}
+	}
+
+	// Apply the timeout.
+	if o.timeout != 0 {
+		client.Timeout = o.timeout
+	}
+
+	// Create a cookie jar to store FreeIPA session cookies.
+	if client.Jar == nil {
+		jar, err := cookiejar.New(&cookiejar.Options{})
+		if err != nil {
+			return nil, err
+		}
+		client.Jar = jar
+	}
+	return client, nil
+}
+
+// Transport which sets the user agent on each request.
+type userAgentTransport struct {
+	userAgent string
+	next      http.RoundTripper
+}
+
+// Set the user agent and send the request with the next transport.
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	next := t.next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	// Requests must not be modified by a transport, so set the header on a copy.
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", t.userAgent)
+	return next.RoundTrip(req)
+}
+
+// Close idle connections of the next transport.
+func (t *userAgentTransport) CloseIdleConnections() {
+	next := t.next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	if closer, ok := next.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
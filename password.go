@@ -0,0 +1,50 @@
+package freeipa
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Change a user's password using the password change form, which works without an active session.
+// This is the only way to recover a user with an expired password, as login is rejected until it is changed.
+// The change is only sent to another server if it could not be sent to the first, so it is never made twice.
+func (c *Client) ChangePassword(ctx context.Context, user, oldPassword, newPassword, otp string) error {
+	return c.eachServer(ctx, false, func(ctx context.Context, srv *server) error {
+		return changePassword(traceSent(ctx), c.client, srv.uriBase, user, oldPassword, newPassword, otp)
+	})
+}
+
+// Change a user's password using the provided http client.
+func changePassword(ctx context.Context, client *http.Client, baseURL, user, oldPassword, newPassword, otp string) error {
+	// Setup form data with the old and new credentials.
+	data := url.Values{
+		"user":         []string{user},
+		"old_password": []string{oldPassword},
+		"new_password": []string{newPassword},
+	}
+	if otp != "" {
+		data.Set("otp", otp)
+	}
+
+	// Setup request for password change.
+	req, err := http.NewRequestWithContext(ctx, "POST", baseURL+"/session/change_password", strings.NewReader(data.Encode()))
+	if err != nil {
+		return fmt.Errorf("error building password change request: %s", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "text/plain")
+	req.Header.Set("Referer", baseURL)
+
+	// Perform the password change.
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	// The result of the password change is provided in the headers.
+	return passwordChangeHTTPError(res)
+}
@@ -2,25 +2,24 @@ package freeipa
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 )
 
-// Standard API version definitation.
-var apiVersion = "2.237"
-
 // Request format.
 type Request struct {
 	Method string        `json:"method"`
 	Params []interface{} `json:"params"`
+	// Set when sending the request again is safe, allowing a command which makes changes to be retried.
+	Idempotent bool `json:"-"`
 }
 
-// Create a new API request.
-func NewRequest(method string, args []interface{}, parms map[string]interface{}) *Request {
-	// Add API version to the parameters.
-	parms["version"] = apiVersion
-
+// Create a new API request. The parameters may be a map, or a struct with ipa tags for each option.
+// The client adds its API version to the parameters when the request is sent.
+func NewRequest(method string, args []interface{}, parms interface{}) *Request {
 	// Create the request.
 	req := &Request{
 		Method: method,
@@ -36,39 +35,151 @@ func NewRequest(method string, args []interface{}, parms map[string]interface{})
 
 // Have the client perform the request.
 func (c *Client) Do(req *Request) (*Response, error) {
-	// Send request.
-	res, err := c.sendRequest(req)
+	return c.DoContext(context.Background(), req)
+}
+
+// Have the client perform the request, canceling it along with any re-authentication when the context is done.
+// If a server is unavailable, the request is sent to the next server.
+func (c *Client) DoContext(ctx context.Context, req *Request) (*Response, error) {
+	// Encode the parameters and add the API version negotiated with the server.
+	req, err := c.prepareRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the request against the schema when validation is enabled.
+	if c.validate {
+		err = c.validateRequest(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+	}
+	resp, err := c.roundTrip(ctx, req)
+
+	// Middleware may return without a response, which callers do not expect without an error.
+	if resp == nil && err == nil {
+		return nil, fmt.Errorf("no response for %s", req.Method)
+	}
+	return resp, err
+}
+
+// Send a prepared request, failing over to the next server if a server is unavailable,
+// and retrying transient failures using the retry policy. Commands which make changes only fail over
+// when they could not be sent, unless the request is marked idempotent.
+func (c *Client) doPrepared(ctx context.Context, req *Request) (*Response, error) {
+	// Requests retried by middleware are only attempted once here, so retries are not multiplied.
+	policy := c.retry
+	if ctx.Value(retryMiddlewareKey{}) != nil {
+		policy.MaxAttempts = 1
+	}
+	return policy.do(ctx, req, func() (*Response, error) {
+		var resp *Response
+		err := c.eachServer(ctx, canResend(req), func(ctx context.Context, srv *server) error {
+			var err error
+			resp, err = c.doWithServer(ctx, srv, req)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		return resp, nil
+	})
+}
+
+// Make a copy of the request with the parameters encoded in FreeIPA's format and the client's API version added,
+// unless the request specifies a version.
+func (c *Client) prepareRequest(req *Request) (*Request, error) {
+	params := make([]interface{}, 2)
+	copy(params, req.Params)
+
+	// Encode the positional arguments.
+	if args, ok := params[0].([]interface{}); ok {
+		encoded := make([]interface{}, len(args))
+		for i, arg := range args {
+			encoded[i] = encodeValue(arg)
+		}
+		params[0] = encoded
+	} else if params[0] == nil {
+		params[0] = []interface{}{}
+	}
+
+	// Encode the options, which also copies them so the caller's request is not modified.
+	options, err := encodeOptions(params[1])
+	if err != nil {
+		return nil, fmt.Errorf("error encoding options for %s: %w", req.Method, err)
+	}
+	if _, ok := options["version"]; !ok {
+		options["version"] = c.apiVersion
+	}
+	params[1] = options
+
+	return &Request{
+		Method:     req.Method,
+		Params:     params,
+		Idempotent: req.Idempotent,
+	}, nil
+}
+
+// Perform the request with the server, logging in first if no session has been established with it.
+func (c *Client) doWithServer(ctx context.Context, srv *server, req *Request) (*Response, error) {
+	// Login to the server if needed, or if the session is about to expire.
+	loggedIn, seq := srv.session()
+	if !loggedIn || srv.shouldRenew() {
+		err := c.loginServer(ctx, srv, seq)
+		if err != nil {
+			return nil, fmt.Errorf("login failed: %w", err)
+		}
+		_, seq = srv.session()
+	}
+
+	// Send request, noting once it is written as it may then be applied even if it fails.
+	res, err := c.sendRequest(traceSent(ctx), srv, req)
 	if err != nil {
 		return nil, err
 	}
 	defer res.Body.Close()
 
 	// If request is unauthorized, attempt to re-authenticate.
+	// Other goroutines sent with the same session wait for a single login, then replay their requests.
 	if res.StatusCode == http.StatusUnauthorized {
+		// The rejected request was not applied.
+		clearSent(ctx)
+
 		// Login.
-		err = c.login()
+		err = c.loginServer(ctx, srv, seq)
 		if err != nil {
-			return nil, fmt.Errorf("renewed login failed: %s", err)
+			return nil, fmt.Errorf("renewed login failed: %w", err)
 		}
 
 		// Re-send the request, now that we're authenticated.
-		res, err = c.sendRequest(req)
+		res, err = c.sendRequest(traceSent(ctx), srv, req)
 		if err != nil {
 			return nil, err
 		}
+		defer res.Body.Close()
 	}
 
 	// We expect a 200 response, otherwise re-authentication failed or some other error occured.
 	if res.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected http status code: %d", res.StatusCode)
+		return nil, unexpectedHTTPError(res)
 	}
 
 	// Parse the response from the body.
-	return ParseResponse(res.Body)
+	resp, err := ParseResponse(res.Body)
+	if err != nil {
+		// Note the HTTP status that the API error was delivered with.
+		var e *Error
+		if errors.As(err, &e) {
+			e.StatusCode = res.StatusCode
+		}
+		return nil, err
+	}
+	resp.Server = srv.host
+	return resp, nil
 }
 
 // Encode and send the request to the session.
-func (c *Client) sendRequest(request *Request) (*http.Response, error) {
+func (c *Client) sendRequest(ctx context.Context, srv *server, request *Request) (*http.Response, error) {
 	// Encode to JSON.
 	data, err := json.Marshal(request)
 	if err != nil {
@@ -76,13 +187,13 @@ func (c *Client) sendRequest(request *Request) (*http.Response, error) {
 	}
 
 	// Make request with JSON data.
-	req, err := http.NewRequest("POST", c.uriBase+"/session/json", bytes.NewBuffer(data))
+	req, err := http.NewRequestWithContext(ctx, "POST", srv.uriBase+"/session/json", bytes.NewBuffer(data))
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
-	req.Header.Set("Referer", c.uriBase)
+	req.Header.Set("Referer", srv.uriBase)
 
 	// Perform the request.
 	return c.client.Do(req)
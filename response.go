@@ -14,15 +14,11 @@ const LDAPGeneralizedTimeFormat = "20060102150405Z"
 
 // Used in providing extra messages and error response.
 type Message struct {
-	Type    string `json:"type"`
-	Message string `json:"message"`
-	Code    int    `json:"code"`
-	Name    string `json:"name"`
-}
-
-// Convert the message into a combind string.
-func (t *Message) string() string {
-	return fmt.Sprintf("%v (%v): %v", t.Name, t.Code, t.Message)
+	Type    string                 `json:"type"`
+	Message string                 `json:"message"`
+	Code    int                    `json:"code"`
+	Name    string                 `json:"name"`
+	Data    map[string]interface{} `json:"data,omitempty"`
 }
 
 // Standard result in response from FreeIPA.
@@ -36,14 +32,54 @@ type Result struct {
 	Result  interface{} `json:"result"`
 	Summary string      `json:"summary,omitempty"`
 	Value   string      `json:"value,omitempty"`
+	// Values of commands which act on multiple entries, such as user_del.
+	Values []string `json:"-"`
+	// Results of each command in a batch request.
+	Results []json.RawMessage `json:"results,omitempty"`
+
+	// The result as decoded from JSON, including outputs not in this struct.
+	raw map[string]interface{}
+}
+
+// Decode the result, allowing the value to be a list and keeping all outputs for typed decoding.
+func (r *Result) UnmarshalJSON(data []byte) error {
+	type result Result
+	aux := struct {
+		*result
+		Value interface{} `json:"value"`
+	}{result: (*result)(r)}
+	err := json.Unmarshal(data, &aux)
+	if err != nil {
+		return err
+	}
+
+	// The value is a string for most commands, and a list when acting on multiple entries.
+	switch value := aux.Value.(type) {
+	case string:
+		r.Value = value
+		r.Values = []string{value}
+	case []interface{}:
+		r.Values = nil
+		for _, v := range value {
+			if s, ok := v.(string); ok {
+				r.Values = append(r.Values, s)
+			}
+		}
+	}
+
+	// Keep all outputs for decoding into generated result types.
+	return json.Unmarshal(data, &r.raw)
 }
 
 // Standard response from FreeIPA.
 type Response struct {
-	Error     *Message `json:"error"`
-	Result    *Result  `json:"result"`
-	Version   string   `json:"version"`
-	Principal string   `json:"principal"`
+	Error  *Message `json:"error"`
+	Result *Result  `json:"result"`
+	// Version of the server which handled the request, useful in detecting version drift between replicas.
+	Version   string `json:"version"`
+	Principal string `json:"principal"`
+	// Host of the server which provided the response.
+	Server string `json:"-"`
 }
 
 // Parse response from reader.
@@ -56,7 +92,7 @@ func ParseResponse(body io.Reader) (*Response, error) {
 	}
 	// If an error was provided from the API, return it.
 	if res.Error != nil {
-		return nil, fmt.Errorf(res.Error.string())
+		return nil, newErrorFromMessage(res.Error)
 	}
 	// We expect result to be provided on a valid response.
 	if res.Result == nil {
@@ -100,7 +136,7 @@ func (r *Response) DictAtIndex(index int) (map[string]interface{}, bool) {
 		return nil, false
 	}
 	// Make sure we don't overflow.
-	if len(a) < index {
+	if index < 0 || len(a) <= index {
 		return nil, false
 	}
 	d := a[index]
@@ -0,0 +1,141 @@
+package freeipa
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Policy for retrying requests which fail with transient errors, such as network errors or database timeouts.
+// Read-only commands are retried, while commands which make changes are only retried when marked idempotent.
+type RetryPolicy struct {
+	// Total number of attempts, including the first. One or less disables retries.
+	MaxAttempts int
+	// Delay before the first retry, which doubles for each following retry up to the maximum.
+	// Each delay is jittered between half and all of its value.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Decides if an error is transient, DefaultRetryable is used if not set.
+	Retryable func(err error) bool
+	// Called before waiting to retry a request.
+	OnRetry func(req *Request, attempt int, err error, delay time.Duration)
+}
+
+// Get the retry policy clients use unless one is configured.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 250 * time.Millisecond,
+		MaxBackoff:     5 * time.Second,
+	}
+}
+
+// Commands which only read data, and are safe to send again.
+var readOnlyCommands = map[string]bool{
+	"env":           true,
+	"i18n_messages": true,
+	"json_metadata": true,
+	"ping":          true,
+	"plugins":       true,
+	"schema":        true,
+	"whoami":        true,
+}
+
+// Check if the command only reads data, such as find and show commands.
+func isReadOnlyCommand(method string) bool {
+	if readOnlyCommands[method] {
+		return true
+	}
+	return strings.HasSuffix(method, "_find") || strings.HasSuffix(method, "_show") || strings.HasSuffix(method, "_is_enabled")
+}
+
+// Check if the error is transient: network errors, unavailable servers behind the web server,
+// and FreeIPA timeout and network errors.
+func DefaultRetryable(err error) bool {
+	// A done context will fail the same way again.
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var e *Error
+	if errors.As(err, &e) {
+		switch e.StatusCode {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		switch e.Code {
+		case DatabaseTimeoutCode, TaskTimeoutCode, NetworkErrorCode, ServerNetworkErrorCode:
+			return true
+		}
+		return false
+	}
+
+	var urlErr *url.Error
+	var netErr net.Error
+	return errors.As(err, &urlErr) || errors.As(err, &netErr)
+}
+
+// Check if the request is safe to send again after it may have been applied, as it only reads data or is idempotent.
+func canResend(req *Request) bool {
+	return isReadOnlyCommand(req.Method) || req.Idempotent
+}
+
+// Check if the request should be retried after the error.
+func (p *RetryPolicy) shouldRetry(req *Request, err error) bool {
+	if !canResend(req) {
+		return false
+	}
+	retryable := p.Retryable
+	if retryable == nil {
+		retryable = DefaultRetryable
+	}
+	return retryable(err)
+}
+
+// Get the jittered delay before the retry following the attempt.
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.InitialBackoff
+	for i := 1; i < attempt && (p.MaxBackoff <= 0 || delay < p.MaxBackoff); i++ {
+		delay *= 2
+	}
+	if p.MaxBackoff > 0 && delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// Call the function until it succeeds, fails with an error which should not be retried, or runs out of attempts.
+// Retries are not attempted if the delay would pass the context deadline.
+func (p *RetryPolicy) do(ctx context.Context, req *Request, fn func() (*Response, error)) (*Response, error) {
+	for attempt := 1; ; attempt++ {
+		resp, err := fn()
+		if err == nil || attempt >= p.MaxAttempts || !p.shouldRetry(req, err) {
+			return resp, err
+		}
+
+		// Only wait if the retry can happen before the deadline.
+		delay := p.backoff(attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(delay).After(deadline) {
+			return resp, err
+		}
+		if p.OnRetry != nil {
+			p.OnRetry(req, attempt, err, delay)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return resp, err
+		case <-timer.C:
+		}
+	}
+}
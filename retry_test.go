@@ -0,0 +1,106 @@
+package freeipa
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Confirm transient failures are retried for read-only and idempotent requests only.
+func TestRetryPolicy(t *testing.T) {
+	failures := 0
+	calls := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ipa/session/login_password", handleLogin)
+	mux.HandleFunc("/ipa/session/json", func(w http.ResponseWriter, req *http.Request) {
+		res := new(Request)
+		json.NewDecoder(req.Body).Decode(res)
+		calls++
+		if failures > 0 {
+			failures--
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			return
+		}
+		if res.Method == "user_mod" {
+			fmt.Fprintf(w, `{"result": {"summary": null, "result": {"uid": ["admin"]}, "value": "admin"}, "version": "4.9.8", "error": null, "id": null, "principal": "test@EXAMPLE.COM"}`)
+			return
+		}
+		fmt.Fprintf(w, `{"result": null, "version": "4.9.8", "error": {"code": 4211, "name": "DatabaseTimeout", "message": "LDAP timeout", "data": {}}, "id": null, "principal": "test@EXAMPLE.COM"}`)
+	})
+	srv := httptest.NewTLSServer(mux)
+	defer srv.Close()
+
+	retries := 0
+	client, err := NewClient(
+		strings.TrimPrefix(srv.URL, "https://"),
+		WithTransport(srv.Client().Transport.(*http.Transport)),
+		WithAuthenticator(&PasswordAuthenticator{User: "test", Password: "testpassword"}),
+		WithAPIVersion("2.245"),
+		WithRetryPolicy(RetryPolicy{
+			MaxAttempts:    3,
+			InitialBackoff: time.Millisecond,
+			MaxBackoff:     2 * time.Millisecond,
+			OnRetry: func(req *Request, attempt int, err error, delay time.Duration) {
+				retries++
+			},
+		}),
+	)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+
+	// A read-only command is retried until attempts run out.
+	_, err = client.Do(NewRequest("user_show", []interface{}{"admin"}, nil))
+	if !errors.Is(err, ErrDatabaseTimeout) || calls != 3 || retries != 2 {
+		t.Errorf("expected 3 attempts: %v %d %d", err, calls, retries)
+	}
+
+	// A mutating command is not retried.
+	calls, retries, failures = 0, 0, 1
+	_, err = client.Do(NewRequest("user_mod", []interface{}{"admin"}, nil))
+	if err == nil || calls != 1 || retries != 0 {
+		t.Errorf("expected no retries: %v %d %d", err, calls, retries)
+	}
+
+	// Unless it is marked idempotent.
+	calls, retries, failures = 0, 0, 2
+	req := NewRequest("user_mod", []interface{}{"admin"}, nil)
+	req.Idempotent = true
+	resp, err := client.Do(req)
+	if err != nil || resp.Result.Value != "admin" || calls != 3 || retries != 2 {
+		t.Errorf("expected success after retries: %v %d %d", err, calls, retries)
+	}
+
+	// Retries which would pass the deadline are not attempted.
+	client.retry.InitialBackoff = time.Second
+	client.retry.MaxBackoff = time.Second
+	calls, retries, failures = 0, 0, 1
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = client.DoContext(ctx, NewRequest("user_show", []interface{}{"admin"}, nil))
+	if err == nil || calls != 1 || retries != 0 {
+		t.Errorf("expected no retry past deadline: %v %d %d", err, calls, retries)
+	}
+}
+
+// Confirm which errors are transient.
+func TestDefaultRetryable(t *testing.T) {
+	if !DefaultRetryable(&Error{Code: GenericErrorCode, StatusCode: http.StatusBadGateway}) {
+		t.Errorf("expected bad gateway to be retryable")
+	}
+	if !DefaultRetryable(fmt.Errorf("wrapped: %w", &Error{Code: TaskTimeoutCode, StatusCode: http.StatusOK})) {
+		t.Errorf("expected task timeout to be retryable")
+	}
+	if DefaultRetryable(&Error{Code: NotFoundCode, StatusCode: http.StatusOK}) {
+		t.Errorf("expected not found to not be retryable")
+	}
+	if DefaultRetryable(context.Canceled) {
+		t.Errorf("expected canceled context to not be retryable")
+	}
+}
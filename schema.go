@@ -0,0 +1,338 @@
+package freeipa
+
+//go:generate go run ./cmd/freeipa-gen -schema cmd/freeipa-gen/schema.json -o api_generated.go
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// Time to cache a schema when the server does not provide one.
+const defaultSchemaTTL = time.Hour
+
+// API schema as provided by the schema command, describing each command along with its parameters and outputs.
+type Schema struct {
+	Commands []*SchemaCommand `json:"commands"`
+	Classes  []*SchemaClass   `json:"classes"`
+	Topics   []*SchemaTopic   `json:"topics"`
+	// Fingerprint of the schema, which changes when the API changes.
+	Fingerprint string `json:"fingerprint"`
+	// Number of seconds the schema may be cached for.
+	TTL int `json:"ttl"`
+}
+
+// Command in the API schema.
+type SchemaCommand struct {
+	Name     string `json:"name"`
+	FullName string `json:"full_name"`
+	Version  string `json:"version"`
+	Doc      string `json:"doc"`
+	// The class of object the command acts on, such as user/1.
+	ObjClass   string `json:"obj_class"`
+	AttrName   string `json:"attr_name"`
+	TopicTopic string `json:"topic_topic"`
+	// Arguments and options, with arguments marked as positional.
+	Params []*SchemaParam `json:"params"`
+	Output []*SchemaParam `json:"output"`
+}
+
+// Object class in the API schema, such as user or group.
+type SchemaClass struct {
+	Name     string         `json:"name"`
+	FullName string         `json:"full_name"`
+	Version  string         `json:"version"`
+	Params   []*SchemaParam `json:"params"`
+}
+
+// Topic in the API schema, which groups commands for help.
+type SchemaTopic struct {
+	Name     string `json:"name"`
+	FullName string `json:"full_name"`
+	Version  string `json:"version"`
+	Doc      string `json:"doc"`
+}
+
+// Parameter of a command or class in the API schema.
+type SchemaParam struct {
+	Name    string `json:"name"`
+	CLIName string `json:"cli_name"`
+	// Type of the parameter, such as str, int, bool, datetime, bytes, dict, DNSName or Principal.
+	Type    string      `json:"type"`
+	Doc     string      `json:"doc"`
+	Label   string      `json:"label"`
+	Default interface{} `json:"default,omitempty"`
+	// Parameters the default is computed from, which makes a required parameter optional to send.
+	DefaultFromParam []string `json:"default_from_param,omitempty"`
+	Multivalue       bool     `json:"multivalue"`
+	// The server only includes required when false, and positional when it differs from required,
+	// so parameters are required unless marked otherwise and command arguments are those which are required.
+	Required   bool `json:"required"`
+	Positional bool `json:"positional"`
+}
+
+// Decode the parameter, filling in the required and positional flags the server leaves out.
+func (p *SchemaParam) UnmarshalJSON(data []byte) error {
+	type param SchemaParam
+	aux := struct {
+		*param
+		Required   *bool `json:"required"`
+		Positional *bool `json:"positional"`
+	}{param: (*param)(p)}
+	err := json.Unmarshal(data, &aux)
+	if err != nil {
+		return err
+	}
+	p.Required = aux.Required == nil || *aux.Required
+	p.Positional = p.Required
+	if aux.Positional != nil {
+		p.Positional = *aux.Positional
+	}
+	return nil
+}
+
+// Check if the parameter must be sent, as required parameters with a default are filled in by the server.
+func (p *SchemaParam) IsRequired() bool {
+	return p.Required && p.Default == nil && len(p.DefaultFromParam) == 0
+}
+
+// Parse the schema from a schema command response, such as one captured to a file.
+func ParseSchema(r io.Reader) (*Schema, error) {
+	resp, err := ParseResponse(r)
+	if err != nil {
+		return nil, err
+	}
+	return schemaFromResponse(resp)
+}
+
+// Decode the schema from the result of a schema command.
+func schemaFromResponse(resp *Response) (*Schema, error) {
+	if resp.Result == nil || resp.Result.Result == nil {
+		return nil, errors.New("no schema in response")
+	}
+
+	// Re-encode the result to decode it using the JSON tags.
+	data, err := json.Marshal(resp.Result.Result)
+	if err != nil {
+		return nil, err
+	}
+	schema := new(Schema)
+	err = json.Unmarshal(data, schema)
+	if err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
+
+// Find a command in the schema by name.
+func (s *Schema) Command(name string) *SchemaCommand {
+	for _, command := range s.Commands {
+		if command.Name == name {
+			return command
+		}
+	}
+	return nil
+}
+
+// Find a class in the schema by name or full name.
+func (s *Schema) Class(name string) *SchemaClass {
+	for _, class := range s.Classes {
+		if class.Name == name || class.FullName == name {
+			return class
+		}
+	}
+	return nil
+}
+
+// Get the API schema from the server, which is cached until its time to live passes.
+// The cache is keyed by fingerprint, so the schema is only downloaded again when the server's API changes.
+// Servers without the schema command have their schema built from json_metadata.
+// Only one fetch runs at a time, and callers which arrive during it use its result.
+func (c *Client) Schema(ctx context.Context) (*Schema, error) {
+	for {
+		c.schemaMu.Lock()
+
+		// Use the cached schema while it is current.
+		if schema, ok := c.schemas[c.schemaFingerprint]; ok && time.Now().Before(c.schemaExpires) {
+			c.schemaMu.Unlock()
+			return schema, nil
+		}
+
+		// Wait for a fetch in progress, then check the cache again. If it failed, fetch under this context.
+		if wait := c.schemaFetch; wait != nil {
+			c.schemaMu.Unlock()
+			select {
+			case <-wait:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			continue
+		}
+
+		// Tell the server which schemas are known, so it only sends one if the API changed.
+		var known []string
+		for fingerprint := range c.schemas {
+			if fingerprint != "" {
+				known = append(known, fingerprint)
+			}
+		}
+		wait := make(chan struct{})
+		c.schemaFetch = wait
+		c.schemaMu.Unlock()
+
+		// Fetch without the lock held, so the cache can be read while the request is sent.
+		schema, ttl, err := c.fetchSchema(ctx, known)
+		c.schemaMu.Lock()
+		if err == nil {
+			c.cacheSchema(schema, ttl)
+		}
+		c.schemaFetch = nil
+		close(wait)
+		c.schemaMu.Unlock()
+		return schema, err
+	}
+}
+
+// Fetch the schema from the server, returning it with its time to live.
+func (c *Client) fetchSchema(ctx context.Context, known []string) (*Schema, int, error) {
+	options := map[string]interface{}{}
+	if len(known) != 0 {
+		sort.Strings(known)
+		options["known_fingerprints"] = known
+	}
+	req, err := c.prepareRequest(NewRequest("schema", nil, options))
+	if err != nil {
+		return nil, 0, err
+	}
+	resp, err := c.doPrepared(ctx, req)
+
+	// A known schema is still current.
+	var ipaErr *Error
+	if errors.As(err, &ipaErr) && ipaErr.Code == SchemaUpToDateCode {
+		fingerprint, _ := ipaErr.Data["fingerprint"].(string)
+		ttl, _ := ipaErr.Data["ttl"].(float64)
+		c.schemaMu.Lock()
+		schema, ok := c.schemas[fingerprint]
+		c.schemaMu.Unlock()
+		if !ok {
+			return nil, 0, fmt.Errorf("server reported unknown schema %s as up to date", fingerprint)
+		}
+		return schema, int(ttl), nil
+	}
+
+	// Servers before FreeIPA 4.5 only provide json_metadata.
+	var schema *Schema
+	if errors.Is(err, ErrCommand) {
+		req, err = c.prepareRequest(NewRequest("json_metadata", nil, map[string]interface{}{"method": "all"}))
+		if err != nil {
+			return nil, 0, err
+		}
+		resp, err = c.doPrepared(ctx, req)
+		if err != nil {
+			return nil, 0, err
+		}
+		schema, err = schemaFromMetadata(resp)
+	} else if err == nil {
+		schema, err = schemaFromResponse(resp)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	return schema, schema.TTL, nil
+}
+
+// Cache the schema as the current schema, with schemaMu held.
+func (c *Client) cacheSchema(schema *Schema, ttl int) {
+	if c.schemas == nil {
+		c.schemas = make(map[string]*Schema)
+	}
+	c.schemas[schema.Fingerprint] = schema
+	c.schemaFingerprint = schema.Fingerprint
+	expires := defaultSchemaTTL
+	if ttl > 0 {
+		expires = time.Duration(ttl) * time.Second
+	}
+	c.schemaExpires = time.Now().Add(expires)
+}
+
+// Command as described by json_metadata.
+type metadataCommand struct {
+	Name         string          `ipa:"name"`
+	Doc          string          `ipa:"doc"`
+	ObjName      string          `ipa:"obj_name"`
+	AttrName     string          `ipa:"attr_name"`
+	TakesArgs    []metadataParam `ipa:"takes_args"`
+	TakesOptions []metadataParam `ipa:"takes_options"`
+}
+
+// Parameter as described by json_metadata.
+type metadataParam struct {
+	Name       string `ipa:"name"`
+	CLIName    string `ipa:"cli_name"`
+	Type       string `ipa:"type"`
+	Doc        string `ipa:"doc"`
+	Label      string `ipa:"label"`
+	Multivalue bool   `ipa:"multivalue"`
+	Required   bool   `ipa:"required"`
+	// Set when the parameter has a default, which makes a required parameter optional to send.
+	Autofill bool `ipa:"autofill"`
+}
+
+// Build a schema from the methods provided by json_metadata.
+func schemaFromMetadata(resp *Response) (*Schema, error) {
+	var metadata struct {
+		Methods map[string]metadataCommand `ipa:"methods"`
+	}
+	err := resp.DecodeOutput(&metadata)
+	if err != nil {
+		return nil, err
+	}
+	if len(metadata.Methods) == 0 {
+		return nil, errors.New("no methods in metadata")
+	}
+
+	schema := new(Schema)
+	for name, method := range metadata.Methods {
+		command := &SchemaCommand{
+			Name:     name,
+			FullName: name + "/1",
+			Doc:      method.Doc,
+			AttrName: method.AttrName,
+		}
+		if method.ObjName != "" {
+			command.ObjClass = method.ObjName + "/1"
+		}
+		for _, p := range method.TakesArgs {
+			command.Params = append(command.Params, p.schemaParam(true))
+		}
+		for _, p := range method.TakesOptions {
+			command.Params = append(command.Params, p.schemaParam(false))
+		}
+		schema.Commands = append(schema.Commands, command)
+	}
+	sort.Slice(schema.Commands, func(i, j int) bool { return schema.Commands[i].Name < schema.Commands[j].Name })
+	return schema, nil
+}
+
+// Convert the parameter to its schema format.
+func (p metadataParam) schemaParam(positional bool) *SchemaParam {
+	param := &SchemaParam{
+		Name:       p.Name,
+		CLIName:    p.CLIName,
+		Type:       p.Type,
+		Doc:        p.Doc,
+		Label:      p.Label,
+		Multivalue: p.Multivalue,
+		Required:   p.Required && !p.Autofill,
+		Positional: positional,
+	}
+	// Python 2 servers name text parameters unicode.
+	if param.Type == "unicode" {
+		param.Type = "str"
+	}
+	return param
+}
@@ -0,0 +1,193 @@
+package freeipa
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Confirm the schema is fetched, cached and revalidated by fingerprint.
+func TestSchema(t *testing.T) {
+	calls := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ipa/session/login_password", handleLogin)
+	mux.HandleFunc("/ipa/session/json", func(w http.ResponseWriter, req *http.Request) {
+		calls++
+		handleJSON(w, req)
+	})
+	srv := httptest.NewTLSServer(mux)
+	defer srv.Close()
+
+	client, err := NewClient(
+		strings.TrimPrefix(srv.URL, "https://"),
+		WithTransport(srv.Client().Transport.(*http.Transport)),
+		WithAuthenticator(&PasswordAuthenticator{User: "test", Password: "testpassword"}),
+		WithAPIVersion("2.245"),
+	)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	ctx := context.Background()
+
+	// The first call fetches the schema.
+	schema, err := client.Schema(ctx)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	if schema.Fingerprint != GeneratedSchemaFingerprint || schema.TTL != 3600 || schema.Command("user_add") == nil || schema.Class("user/1") == nil {
+		t.Errorf("unexpected schema: %s %d", schema.Fingerprint, schema.TTL)
+	}
+
+	// The cached schema is used until it expires.
+	_, err = client.Schema(ctx)
+	if err != nil || calls != 1 {
+		t.Errorf("expected cached schema: %v %d", err, calls)
+	}
+
+	// Once expired, the server confirms the known schema is current.
+	client.schemaExpires = time.Now()
+	cached, err := client.Schema(ctx)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	if cached != schema || calls != 2 || !client.schemaExpires.After(time.Now()) {
+		t.Errorf("expected known schema to be revalidated: %d", calls)
+	}
+
+	// Invalid requests are not sent by a validating client.
+	client, err = NewClient(
+		strings.TrimPrefix(srv.URL, "https://"),
+		WithTransport(srv.Client().Transport.(*http.Transport)),
+		WithAuthenticator(&PasswordAuthenticator{User: "test", Password: "testpassword"}),
+		WithAPIVersion("2.245"),
+		WithValidation(),
+	)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	calls = 0
+	_, err = client.Do(NewRequest("user_add", []interface{}{"username"}, map[string]interface{}{"givenName": "FreeIPA", "sn": "Test"}))
+	if !errors.Is(err, ErrOption) || calls != 1 {
+		t.Errorf("expected option error without sending: %v %d", err, calls)
+	}
+	_, err = client.UserAdd(ctx, UserAddArgs{Login: "username"}, UserAddOptions{First: "FreeIPA", Last: "Test"})
+	if err != nil || calls != 2 {
+		t.Errorf("expected valid request to be sent: %v %d", err, calls)
+	}
+}
+
+// Confirm concurrent callers share one fetch, and the cache is not locked while it is sent.
+func TestSchemaConcurrent(t *testing.T) {
+	var calls int32
+	entered := make(chan struct{}, 1)
+	release := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ipa/session/login_password", handleLogin)
+	mux.HandleFunc("/ipa/session/json", func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		entered <- struct{}{}
+		<-release
+		handleJSON(w, req)
+	})
+	srv := httptest.NewTLSServer(mux)
+	defer srv.Close()
+
+	client, err := NewClient(
+		strings.TrimPrefix(srv.URL, "https://"),
+		WithTransport(srv.Client().Transport.(*http.Transport)),
+		WithAuthenticator(&PasswordAuthenticator{User: "test", Password: "testpassword"}),
+		WithAPIVersion("2.245"),
+	)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+
+	// Start several callers, and wait for the fetch to reach the server.
+	var wg sync.WaitGroup
+	schemas := make([]*Schema, 5)
+	errs := make([]error, 5)
+	for i := range schemas {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			schemas[i], errs[i] = client.Schema(context.Background())
+		}(i)
+	}
+	<-entered
+
+	// A caller whose context ends stops waiting for the fetch.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = client.Schema(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded while waiting: %v", err)
+	}
+
+	close(release)
+	wg.Wait()
+	for i := range schemas {
+		if errs[i] != nil || schemas[i] != schemas[0] {
+			t.Errorf("%d: expected shared schema: %v", i, errs[i])
+		}
+	}
+	if atomic.LoadInt32(&calls) != 1 {
+		t.Errorf("expected one fetch, got %d", calls)
+	}
+}
+
+// Confirm requests are validated against the schema with the errors the server would return.
+func TestValidation(t *testing.T) {
+	f, err := os.Open("cmd/freeipa-gen/schema.json")
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	defer f.Close()
+	schema, err := ParseSchema(f)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	client := &Client{apiVersion: "2.245"}
+
+	tests := []struct {
+		req  *Request
+		code int
+	}{
+		{NewRequest("user_add", []interface{}{"username"}, map[string]interface{}{"givenname": "FreeIPA", "sn": "Test"}), 0},
+		{NewRequest("user_add", []interface{}{"username"}, UserAddOptions{First: "FreeIPA", Last: "Test", UID: Ptr(1000)}), 0},
+		{NewRequest("user_del", []interface{}{"a", "b", "c"}, nil), 0},
+		{NewRequest("user_find", []interface{}{}, map[string]interface{}{"uidnumber": "1000", "all": true}), 0},
+		{NewRequest("user_find", []interface{}{"user"}, nil), 0},
+		{NewRequest("user_addd", []interface{}{"username"}, nil), CommandErrorCode},
+		{NewRequest("user_show", []interface{}{"username", "extra"}, nil), MaxArgumentErrorCode},
+		{NewRequest("user_add", []interface{}{"username"}, map[string]interface{}{"givenName": "FreeIPA", "sn": "Test"}), OptionErrorCode},
+		{NewRequest("user_add", []interface{}{"username"}, map[string]interface{}{"sn": "Test"}), RequirementErrorCode},
+		{NewRequest("user_show", []interface{}{}, nil), RequirementErrorCode},
+		{NewRequest("user_mod", []interface{}{"username"}, map[string]interface{}{"givenname": []string{"a", "b"}}), ConversionErrorCode},
+		{NewRequest("user_find", []interface{}{}, map[string]interface{}{"uidnumber": "abc"}), ConversionErrorCode},
+		{NewRequest("user_find", []interface{}{}, map[string]interface{}{"all": "maybe"}), ConversionErrorCode},
+	}
+	for i, test := range tests {
+		req, err := client.prepareRequest(test.req)
+		if err != nil {
+			t.Fatalf("error: %s", err)
+		}
+		err = validateRequest(schema, req)
+		if test.code == 0 {
+			if err != nil {
+				t.Errorf("%d: unexpected error: %s", i, err)
+			}
+			continue
+		}
+		var ipaErr *Error
+		if !errors.As(err, &ipaErr) || ipaErr.Code != test.code || !errors.Is(err, ErrInvocation) && test.code != CommandErrorCode {
+			t.Errorf("%d: unexpected error: %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package freeipa
+
+import (
+	"context"
+	"errors"
+	"net/http/httptrace"
+	"net/url"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Policy for choosing the order servers are tried in.
+type ServerPolicy int
+
+// Server policies.
+const (
+	// Try servers in the order they were provided, using the next only when one fails.
+	ServerPolicyFailover ServerPolicy = iota
+	// Rotate the first server tried on each request to spread load across replicas.
+	ServerPolicyRoundRobin
+	// Try the server with the lowest latency on its last request first.
+	ServerPolicyLeastLatency
+)
+
+// A FreeIPA server along with its session and health state.
+type server struct {
+	host    string
+	uriBase string
+
+	mu       sync.Mutex
+	loggedIn bool
+	// Number of login attempts, and the error from the last one.
+	loginSeq    uint64
+	loginErr    error
+	expires     time.Time
+	renewAt     time.Time
+	healthy     bool
+	failures    int
+	lastError   error
+	lastLatency time.Duration
+	lastUsed    time.Time
+}
+
+// Health of a server as tracked by the client.
+type ServerStatus struct {
+	Host     string
+	URL      string
+	Healthy  bool
+	LoggedIn bool
+	// Number of failures since the last successful request.
+	Failures int
+	// Error from the last failure, cleared once a request succeeds.
+	LastError   error
+	LastLatency time.Duration
+	LastUsed    time.Time
+}
+
+// Make a server for the host.
+func newServer(host, basePath string) (*server, error) {
+	// Set uriBase using the provided host and test to verify a valid URL is produced.
+	uriBase := "https://" + host + basePath
+	_, err := url.Parse(uriBase)
+	if err != nil {
+		return nil, err
+	}
+	return &server{
+		host:    host,
+		uriBase: uriBase,
+		healthy: true,
+	}, nil
+}
+
+// Check if a session has been established with the server, along with the number of login attempts so far.
+func (s *server) session() (bool, uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.loggedIn, s.loginSeq
+}
+
+// Get the result of the last login attempt if there has been one since the sequence number was observed.
+func (s *server) loginSince(seq uint64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.loginSeq != seq, s.loginErr
+}
+
+// Record the result of a login attempt.
+func (s *server) recordLogin(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loginSeq++
+	s.loginErr = err
+	if err == nil {
+		s.loggedIn = true
+	}
+}
+
+// Note when the session with the server expires, to login again shortly before.
+// Short sessions are renewed once three quarters of their lifetime has passed.
+func (s *server) setExpires(expires time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expires = expires
+	window := time.Until(expires) / 4
+	if window > sessionRenewWindow {
+		window = sessionRenewWindow
+	} else if window < 0 {
+		window = 0
+	}
+	s.renewAt = expires.Add(-window)
+}
+
+// Get when the session with the server expires, zero if unknown.
+func (s *server) sessionExpires() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.expires
+}
+
+// Check if the session with the server should be renewed, as it is about to expire.
+func (s *server) shouldRenew() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !s.renewAt.IsZero() && !time.Now().Before(s.renewAt)
+}
+
+// Note whether a session has been established with the server.
+func (s *server) setLoggedIn(loggedIn bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loggedIn = loggedIn
+}
+
+// Record the result of a request to the server.
+func (s *server) record(latency time.Duration, err error, failed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastUsed = time.Now()
+	if failed {
+		s.healthy = false
+		s.failures++
+		s.lastError = err
+		return
+	}
+	s.healthy = true
+	s.failures = 0
+	s.lastError = nil
+	s.lastLatency = latency
+}
+
+// Get the current status of the server.
+func (s *server) status() ServerStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return ServerStatus{
+		Host:        s.host,
+		URL:         s.uriBase,
+		Healthy:     s.healthy,
+		LoggedIn:    s.loggedIn,
+		Failures:    s.failures,
+		LastError:   s.lastError,
+		LastLatency: s.lastLatency,
+		LastUsed:    s.lastUsed,
+	}
+}
+
+// Get the status of each server the client is configured with.
+func (c *Client) Servers() []ServerStatus {
+	statuses := make([]ServerStatus, len(c.servers))
+	for i, srv := range c.servers {
+		statuses[i] = srv.status()
+	}
+	return statuses
+}
+
+// Determine the order to try servers in using the policy. Unhealthy servers are tried last.
+func (c *Client) serverOrder() []*server {
+	// Take a snapshot of the server health to sort with.
+	type candidate struct {
+		srv     *server
+		healthy bool
+		latency time.Duration
+	}
+	candidates := make([]candidate, len(c.servers))
+	for i, srv := range c.servers {
+		status := srv.status()
+		candidates[i] = candidate{srv, status.Healthy, status.LastLatency}
+	}
+
+	switch c.policy {
+	case ServerPolicyRoundRobin:
+		// Rotate the servers so each request starts with the next server.
+		n := int((atomic.AddUint32(&c.next, 1) - 1) % uint32(len(candidates)))
+		candidates = append(candidates[n:], candidates[:n]...)
+	case ServerPolicyLeastLatency:
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return candidates[i].latency < candidates[j].latency
+		})
+	}
+
+	// Keep unhealthy servers as a last resort.
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].healthy && !candidates[j].healthy
+	})
+
+	servers := make([]*server, len(candidates))
+	for i, candidate := range candidates {
+		servers[i] = candidate.srv
+	}
+	return servers
+}
+
+// Check if an error indicates the server is unavailable.
+func isUnavailable(ctx context.Context, err error) bool {
+	// If the context is done, other servers will fail the same way.
+	if ctx.Err() != nil {
+		return false
+	}
+
+	// Server errors from the web server, rather than errors from the API.
+	var e *Error
+	if errors.As(err, &e) {
+		return e.StatusCode >= 500
+	}
+
+	// Network errors from the http client.
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+// Tracks whether a request was written to a server, and so may have been applied even if it failed.
+type sentFlag struct {
+	sent atomic.Bool
+}
+
+// Key of the sent flag in a context.
+type sentFlagKey struct{}
+
+// Note in the context when the request sent with it has been written, if the context tracks it.
+// Only requests which must not be sent twice should be traced, such as commands and password changes, not logins.
+func traceSent(ctx context.Context) context.Context {
+	flag, ok := ctx.Value(sentFlagKey{}).(*sentFlag)
+	if !ok {
+		return ctx
+	}
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		WroteRequest: func(info httptrace.WroteRequestInfo) {
+			if info.Err == nil {
+				flag.sent.Store(true)
+			}
+		},
+	})
+}
+
+// Note in the context that the request sent with it was rejected without being applied, such as for an expired session.
+func clearSent(ctx context.Context) {
+	if flag, ok := ctx.Value(sentFlagKey{}).(*sentFlag); ok {
+		flag.sent.Store(false)
+	}
+}
+
+// Call the function with each server in order until it succeeds, or fails with an error that another server would not fix.
+// Unless the request can be resent, another server is only tried if the request was not written to the failed server,
+// such as when the connection could not be made, as the failed server may have applied it.
+func (c *Client) eachServer(ctx context.Context, resendable bool, fn func(ctx context.Context, srv *server) error) error {
+	var err error
+	for _, srv := range c.serverOrder() {
+		flag := new(sentFlag)
+		start := time.Now()
+		err = fn(context.WithValue(ctx, sentFlagKey{}, flag), srv)
+		unavailable := err != nil && isUnavailable(ctx, err)
+		srv.record(time.Since(start), err, unavailable)
+		if !unavailable || (!resendable && flag.sent.Load()) {
+			return err
+		}
+	}
+	return err
+}
@@ -0,0 +1,213 @@
+package freeipa
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Lifetime of a session when the session cookie does not provide one, matching FreeIPA's default session_auth_duration.
+const DefaultSessionLifetime = 20 * time.Minute
+
+// Most time before a session expires that the client logs in again, rather than waiting for a request to be rejected.
+const sessionRenewWindow = time.Minute
+
+// Time allowed for logging out when closing a client.
+const closeLogoutTimeout = 10 * time.Second
+
+// Name of the FreeIPA session cookie.
+const sessionCookieName = "ipa_session"
+
+// Transport which watches for the session cookie to track when each server's session expires.
+type sessionTransport struct {
+	client *Client
+	next   http.RoundTripper
+}
+
+// Send the request with the next transport, noting any session cookie set in the response.
+func (t *sessionTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	next := t.next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	res, err := next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	for _, cookie := range res.Cookies() {
+		if cookie.Name == sessionCookieName {
+			t.client.observeSession(req, cookie)
+		}
+	}
+	return res, nil
+}
+
+// Close idle connections of the next transport, so closing the client releases them.
+func (t *sessionTransport) CloseIdleConnections() {
+	next := t.next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	if closer, ok := next.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
+// Update the session expiry of the server the request was sent to from the session cookie.
+func (c *Client) observeSession(req *http.Request, cookie *http.Cookie) {
+	var srv *server
+	for _, s := range c.servers {
+		if strings.HasPrefix(req.URL.String(), s.uriBase+"/") {
+			srv = s
+			break
+		}
+	}
+	if srv == nil {
+		return
+	}
+
+	// A removed cookie ends the session, otherwise use the expiry provided or the configured lifetime.
+	now := time.Now()
+	switch {
+	case cookie.MaxAge < 0 || cookie.Value == "":
+		srv.setExpires(now)
+	case cookie.MaxAge > 0:
+		srv.setExpires(now.Add(time.Duration(cookie.MaxAge) * time.Second))
+	case !cookie.Expires.IsZero():
+		srv.setExpires(cookie.Expires)
+	default:
+		srv.setExpires(now.Add(c.sessionLifetime))
+	}
+}
+
+// Send a ping to each server with a session at the interval, which keeps sessions from expiring while idle
+// and renews them before they expire. Stops when the client is closed.
+func (c *Client) keepAlive(interval time.Duration) {
+	defer close(c.keepAliveDone)
+
+	// Cancel any ping in progress when the client is closed.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-c.closing
+		cancel()
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for _, srv := range c.servers {
+			loggedIn, _ := srv.session()
+			if !loggedIn {
+				continue
+			}
+			req, err := c.prepareRequest(NewRequest("ping", nil, nil))
+			if err != nil {
+				continue
+			}
+			pingCtx, pingCancel := context.WithTimeout(ctx, interval)
+			c.doWithServer(pingCtx, srv, req)
+			pingCancel()
+		}
+	}
+}
+
+// End the session with each server using session_logout, and remove the session cookies along with any stored sessions.
+// The client logs in again if used after logging out. Calling Logout more than once has no effect.
+func (c *Client) Logout(ctx context.Context) error {
+	req, err := c.prepareRequest(NewRequest("session_logout", nil, nil))
+	if err != nil {
+		return err
+	}
+
+	var logoutErr error
+	for _, srv := range c.servers {
+		loggedIn, _ := srv.session()
+		if !loggedIn {
+			continue
+		}
+
+		// Sessions which already expired are rejected, which is the same as logging out.
+		res, err := c.sendRequest(ctx, srv, req)
+		if err == nil {
+			res.Body.Close()
+			if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusUnauthorized {
+				err = unexpectedHTTPError(res)
+			}
+		}
+		if err != nil && logoutErr == nil {
+			logoutErr = fmt.Errorf("logout from %s failed: %w", srv.host, err)
+		}
+
+		srv.setLoggedIn(false)
+		srv.setExpires(time.Time{})
+		c.clearCookies(srv)
+		err = c.deleteSession(ctx, srv)
+		if err != nil && logoutErr == nil {
+			logoutErr = fmt.Errorf("error deleting stored session for %s: %w", srv.host, err)
+		}
+	}
+	return logoutErr
+}
+
+// Remove cookies the jar holds for the server's session.
+func (c *Client) clearCookies(srv *server) {
+	if c.client.Jar == nil {
+		return
+	}
+	u, err := sessionURL(srv)
+	if err != nil {
+		return
+	}
+	cookies := c.client.Jar.Cookies(u)
+	if len(cookies) == 0 {
+		return
+	}
+
+	// The jar does not provide the path of each cookie, so expire them at each path that applies to the session.
+	paths := []string{"/"}
+	for i := 1; i < len(u.Path); i++ {
+		if u.Path[i] == '/' {
+			paths = append(paths, u.Path[:i])
+		}
+	}
+	paths = append(paths, u.Path)
+	for _, path := range paths {
+		expired := make([]*http.Cookie, len(cookies))
+		for i, cookie := range cookies {
+			expired[i] = &http.Cookie{Name: cookie.Name, Path: path, MaxAge: -1}
+		}
+		c.client.Jar.SetCookies(u, expired)
+	}
+}
+
+// Logout, stop background work and close idle connections. The client should not be used after it is closed.
+// With a session store, the session is kept for the next client instead of logging out, so use Logout to end it.
+// Calling Close more than once has no effect.
+func (c *Client) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		// Stop the keep-alive first, so it does not login again.
+		close(c.closing)
+		if c.keepAliveDone != nil {
+			<-c.keepAliveDone
+		}
+
+		// Stored sessions are left for the next client to reuse.
+		if c.store == nil {
+			ctx, cancel := context.WithTimeout(context.Background(), closeLogoutTimeout)
+			defer cancel()
+			err = c.Logout(ctx)
+		}
+		c.client.CloseIdleConnections()
+	})
+	return err
+}
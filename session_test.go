@@ -0,0 +1,356 @@
+package freeipa
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Stub server which issues a new session on each login, and can expire the current session.
+type sessionStub struct {
+	logins   int32
+	session  int32
+	requests int32
+	rejected int32
+	logouts  int32
+	// Set to reply to requests with 503 Service Unavailable.
+	unavailable int32
+	password    string
+	// Lifetime of sessions in seconds, sent as the cookie's Max-Age if set.
+	maxAge int
+}
+
+// Issue a new session on login.
+func (s *sessionStub) handleLogin(w http.ResponseWriter, req *http.Request) {
+	req.ParseForm()
+	if req.Form.Get("password") != s.password {
+		w.Header().Set(rejectionReasonHTTPHeader, invalidSessionPasswordUnauthorizedReason)
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	atomic.AddInt32(&s.logins, 1)
+	session := atomic.AddInt32(&s.session, 1)
+	http.SetCookie(w, &http.Cookie{Name: "ipa_session", Value: fmt.Sprintf("session-%d", session), Path: "/ipa", MaxAge: s.maxAge})
+}
+
+// Accept requests with the current session.
+func (s *sessionStub) handleJSON(w http.ResponseWriter, req *http.Request) {
+	atomic.AddInt32(&s.requests, 1)
+	if atomic.LoadInt32(&s.unavailable) != 0 {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+	cookie, err := req.Cookie("ipa_session")
+	if err != nil || cookie.Value != fmt.Sprintf("session-%d", atomic.LoadInt32(&s.session)) {
+		atomic.AddInt32(&s.rejected, 1)
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	// End the session on logout, leaving the client to remove the cookie.
+	res := new(Request)
+	json.NewDecoder(req.Body).Decode(res)
+	if res.Method == "session_logout" {
+		atomic.AddInt32(&s.logouts, 1)
+		atomic.AddInt32(&s.session, 1)
+		fmt.Fprintf(w, `{"result": {"result": null}, "version": "4.9.8", "error": null, "id": null, "principal": "test@EXAMPLE.COM"}`)
+		return
+	}
+	fmt.Fprintf(w, `{"result": {"summary": "IPA server version 4.9.8. API version 2.245"}, "version": "4.9.8", "error": null, "id": null, "principal": "test@EXAMPLE.COM"}`)
+}
+
+// Start a TLS server for the stub.
+func (s *sessionStub) start() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ipa/session/login_password", s.handleLogin)
+	mux.HandleFunc("/ipa/session/json", s.handleJSON)
+	return httptest.NewTLSServer(mux)
+}
+
+// Confirm goroutines sharing an expired session only login once, run with -race to check for data races.
+func TestConcurrentRelogin(t *testing.T) {
+	stub := &sessionStub{password: "testpassword"}
+	srv := stub.start()
+	defer srv.Close()
+
+	auth := &PasswordAuthenticator{User: "test", Password: "testpassword"}
+	client, err := NewClient(
+		strings.TrimPrefix(srv.URL, "https://"),
+		WithTransport(srv.Client().Transport.(*http.Transport)),
+		WithAuthenticator(auth),
+		WithAPIVersion("2.245"),
+	)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+
+	// Expire the session, then send requests from many goroutines at once.
+	run := func() []error {
+		atomic.AddInt32(&stub.session, 1)
+		var wg sync.WaitGroup
+		errs := make([]error, 50)
+		for i := range errs {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				_, errs[i] = client.DoContext(context.Background(), NewRequest("ping", nil, nil))
+			}(i)
+		}
+		wg.Wait()
+		return errs
+	}
+	for _, err := range run() {
+		if err != nil {
+			t.Fatalf("error: %s", err)
+		}
+	}
+	if logins := atomic.LoadInt32(&stub.logins); logins != 2 {
+		t.Errorf("expected one login after the initial login: %d", logins)
+	}
+
+	// A failed login is returned to each waiting goroutine.
+	stub.password = "changed"
+	failed := 0
+	for _, err := range run() {
+		if IsAuthentication(err) {
+			failed++
+		}
+	}
+	if failed != 50 {
+		t.Errorf("expected all requests to fail authentication: %d", failed)
+	}
+}
+
+// Confirm closing the client closes its idle connections, through the transports wrapping the provided one.
+func TestCloseIdleConnections(t *testing.T) {
+	stub := &sessionStub{password: "testpassword"}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ipa/session/login_password", stub.handleLogin)
+	mux.HandleFunc("/ipa/session/json", stub.handleJSON)
+	var closed int32
+	srv := httptest.NewUnstartedServer(mux)
+	srv.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateClosed {
+			atomic.AddInt32(&closed, 1)
+		}
+	}
+	srv.StartTLS()
+	defer srv.Close()
+
+	// A separate transport, so connections are only closed by the client.
+	transport := srv.Client().Transport.(*http.Transport).Clone()
+	client, err := NewClient(
+		strings.TrimPrefix(srv.URL, "https://"),
+		WithTransport(transport),
+		WithAuthenticator(&PasswordAuthenticator{User: "test", Password: "testpassword"}),
+		WithAPIVersion("2.245"),
+		WithUserAgent("test-agent"),
+	)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	_, err = client.Do(NewRequest("ping", nil, nil))
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	if atomic.LoadInt32(&closed) != 0 {
+		t.Fatalf("expected connections to be kept alive: %d", closed)
+	}
+
+	// Closing the client closes the pooled connections, which the server sees closed.
+	err = client.Close()
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&closed) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if atomic.LoadInt32(&closed) == 0 {
+		t.Errorf("expected idle connections to be closed")
+	}
+}
+
+// Confirm a login canceled by one caller is attempted again for the callers waiting on it.
+func TestCanceledLogin(t *testing.T) {
+	stub := &sessionStub{password: "testpassword"}
+	srv := stub.start()
+	defer srv.Close()
+
+	// Authenticator which blocks until its context is done once enabled.
+	var block int32
+	started := make(chan struct{})
+	password := &PasswordAuthenticator{User: "test", Password: "testpassword"}
+	auth := AuthenticatorFunc(func(ctx context.Context, client *http.Client, baseURL string) error {
+		if atomic.CompareAndSwapInt32(&block, 1, 0) {
+			close(started)
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		return password.Login(ctx, client, baseURL)
+	})
+	client, err := NewClient(
+		strings.TrimPrefix(srv.URL, "https://"),
+		WithTransport(srv.Client().Transport.(*http.Transport)),
+		WithAuthenticator(auth),
+		WithAPIVersion("2.245"),
+	)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+
+	// Start a login which is canceled while another caller waits for it.
+	atomic.StoreInt32(&block, 1)
+	server := client.servers[0]
+	_, seq := server.session()
+	ctx, cancel := context.WithCancel(context.Background())
+	canceled := make(chan error)
+	go func() {
+		canceled <- client.loginServer(ctx, server, seq)
+	}()
+	<-started
+	waited := make(chan error)
+	go func() {
+		waited <- client.loginServer(context.Background(), server, seq)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	if err := <-canceled; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected canceled login: %v", err)
+	}
+	if err := <-waited; err != nil {
+		t.Errorf("expected waiting caller to login: %v", err)
+	}
+	if logins := atomic.LoadInt32(&stub.logins); logins != 2 {
+		t.Errorf("expected waiting caller to login again: %d", logins)
+	}
+}
+
+// Confirm sessions are renewed before they expire, rather than after a request is rejected.
+func TestSessionExpiry(t *testing.T) {
+	stub := &sessionStub{password: "testpassword", maxAge: 2}
+	srv := stub.start()
+	defer srv.Close()
+
+	client, err := NewClient(
+		strings.TrimPrefix(srv.URL, "https://"),
+		WithTransport(srv.Client().Transport.(*http.Transport)),
+		WithAuthenticator(&PasswordAuthenticator{User: "test", Password: "testpassword"}),
+		WithAPIVersion("2.245"),
+	)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	defer client.Close()
+
+	// The session is used while it is fresh.
+	_, err = client.Do(NewRequest("ping", nil, nil))
+	if err != nil || atomic.LoadInt32(&stub.logins) != 1 {
+		t.Fatalf("expected session to be used: %v %d", err, stub.logins)
+	}
+
+	// Close to expiry, the client logs in again before sending the request.
+	time.Sleep(1600 * time.Millisecond)
+	_, err = client.Do(NewRequest("ping", nil, nil))
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	if atomic.LoadInt32(&stub.logins) != 2 || atomic.LoadInt32(&stub.rejected) != 0 {
+		t.Errorf("expected renewal without rejection: %d %d", stub.logins, stub.rejected)
+	}
+}
+
+// Confirm the keep-alive pings servers until the client is closed.
+func TestKeepAlive(t *testing.T) {
+	stub := &sessionStub{password: "testpassword"}
+	srv := stub.start()
+	defer srv.Close()
+
+	client, err := NewClient(
+		strings.TrimPrefix(srv.URL, "https://"),
+		WithTransport(srv.Client().Transport.(*http.Transport)),
+		WithAuthenticator(&PasswordAuthenticator{User: "test", Password: "testpassword"}),
+		WithAPIVersion("2.245"),
+		WithKeepAlive(20*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	time.Sleep(150 * time.Millisecond)
+	client.Close()
+	pings := atomic.LoadInt32(&stub.requests)
+	if pings < 2 {
+		t.Errorf("expected keep-alive pings: %d", pings)
+	}
+
+	// No pings are sent once closed, and closing again has no effect.
+	time.Sleep(60 * time.Millisecond)
+	client.Close()
+	if atomic.LoadInt32(&stub.requests) != pings {
+		t.Errorf("expected keep-alive to stop")
+	}
+}
+
+// Confirm logging out ends the session and clears cookies, and closing logs out once.
+func TestLogout(t *testing.T) {
+	stub := &sessionStub{password: "testpassword"}
+	srv := stub.start()
+	defer srv.Close()
+
+	client, err := NewClient(
+		strings.TrimPrefix(srv.URL, "https://"),
+		WithTransport(srv.Client().Transport.(*http.Transport)),
+		WithAuthenticator(&PasswordAuthenticator{User: "test", Password: "testpassword"}),
+		WithAPIVersion("2.245"),
+	)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	ctx := context.Background()
+
+	// Logout ends the session and removes the cookie from the jar.
+	err = client.Logout(ctx)
+	if err != nil || atomic.LoadInt32(&stub.logouts) != 1 {
+		t.Fatalf("expected logout: %v %d", err, stub.logouts)
+	}
+	u, _ := url.Parse(srv.URL + "/ipa/session/json")
+	if cookies := client.client.Jar.Cookies(u); len(cookies) != 0 {
+		t.Errorf("expected cookies to be cleared: %v", cookies)
+	}
+	if client.Servers()[0].LoggedIn {
+		t.Errorf("expected server to be logged out")
+	}
+
+	// Logging out again does nothing.
+	err = client.Logout(ctx)
+	if err != nil || atomic.LoadInt32(&stub.logouts) != 1 {
+		t.Errorf("expected no second logout: %v %d", err, stub.logouts)
+	}
+
+	// The client logs in again when used.
+	_, err = client.Do(NewRequest("ping", nil, nil))
+	if err != nil || atomic.LoadInt32(&stub.logins) != 2 {
+		t.Errorf("expected login after logout: %v %d", err, stub.logins)
+	}
+
+	// Closing logs out once.
+	err = client.Close()
+	if err != nil || atomic.LoadInt32(&stub.logouts) != 2 {
+		t.Errorf("expected logout on close: %v %d", err, stub.logouts)
+	}
+	err = client.Close()
+	if err != nil || atomic.LoadInt32(&stub.logouts) != 2 {
+		t.Errorf("expected no logout on second close: %v %d", err, stub.logouts)
+	}
+}
@@ -0,0 +1,265 @@
+package freeipa
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Storage for sessions, allowing them to be reused across process restarts.
+// Sessions are stored by the URL of the server along with the user or principal logged in as, so clients with
+// different identities may share a store. Custom authenticators do not provide their identity, so clients using them
+// should only share a store with clients logging in as the same identity.
+type SessionStore interface {
+	// Load the session stored for the key, returning nil data if none is stored.
+	// Stored data which cannot be read, such as data which fails to decrypt, should be reported with ErrInvalidSession
+	// so it is removed, while other errors leave the session stored.
+	Load(ctx context.Context, key string) ([]byte, error)
+	Save(ctx context.Context, key string, data []byte) error
+	Delete(ctx context.Context, key string) error
+}
+
+// Error for stored sessions which cannot be used, and so are removed from the store.
+var ErrInvalidSession = errors.New("stored session is invalid")
+
+// Session as saved in a store.
+type storedSession struct {
+	Cookies []storedCookie `json:"cookies"`
+	Expires time.Time      `json:"expires,omitempty"`
+}
+
+// Cookie of a stored session.
+type storedCookie struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Session store which saves each session to a file in a directory, encrypted with AES-GCM.
+type FileSessionStore struct {
+	dir  string
+	aead cipher.AEAD
+}
+
+// Make a file session store in the directory, encrypting sessions with the key which must be 16, 24 or 32 bytes.
+func NewFileSessionStore(dir string, key []byte) (*FileSessionStore, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSessionStore{
+		dir:  dir,
+		aead: aead,
+	}, nil
+}
+
+// Get the path of the file for the key.
+func (s *FileSessionStore) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:])+".session")
+}
+
+// Load and decrypt the session stored for the key.
+func (s *FileSessionStore) Load(ctx context.Context, key string) ([]byte, error) {
+	data, err := os.ReadFile(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The nonce is stored before the encrypted session, which is bound to the key it was saved for.
+	nonceSize := s.aead.NonceSize()
+	if len(data) < nonceSize {
+		return nil, fmt.Errorf("%w: too short", ErrInvalidSession)
+	}
+	data, err = s.aead.Open(nil, data[:nonceSize], data[nonceSize:], []byte(key))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidSession, err)
+	}
+	return data, nil
+}
+
+// Encrypt and save the session for the key, readable only by the current user.
+func (s *FileSessionStore) Save(ctx context.Context, key string, data []byte) error {
+	err := os.MkdirAll(s.dir, 0700)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, s.aead.NonceSize())
+	_, err = io.ReadFull(rand.Reader, nonce)
+	if err != nil {
+		return err
+	}
+	sealed := s.aead.Seal(nonce, nonce, data, []byte(key))
+
+	// Write to a temporary file and rename it, so a partially written session is never loaded.
+	f, err := os.CreateTemp(s.dir, ".session-*")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(sealed)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), s.path(key))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// Delete the session stored for the key.
+func (s *FileSessionStore) Delete(ctx context.Context, key string) error {
+	err := os.Remove(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// Get the URL session cookies are sent to for the server.
+func sessionURL(srv *server) (*url.URL, error) {
+	return url.Parse(srv.uriBase + "/session/json")
+}
+
+// Get the key the session with the server is stored under, including the identity logged in as if known.
+func (c *Client) sessionKey(srv *server) string {
+	if id, ok := c.auth.(identifier); ok && id.identity() != "" {
+		return id.identity() + " " + srv.uriBase
+	}
+	return srv.uriBase
+}
+
+// Save the session with the server to the store. Errors are ignored, as the session still works without being saved.
+func (c *Client) saveSession(ctx context.Context, srv *server) {
+	if c.store == nil {
+		return
+	}
+	u, err := sessionURL(srv)
+	if err != nil {
+		return
+	}
+	session := storedSession{Expires: srv.sessionExpires()}
+	for _, cookie := range c.client.Jar.Cookies(u) {
+		session.Cookies = append(session.Cookies, storedCookie{Name: cookie.Name, Value: cookie.Value})
+	}
+	data, err := json.Marshal(session)
+	if err != nil {
+		return
+	}
+	c.store.Save(ctx, c.sessionKey(srv), data)
+}
+
+// Remove the session with the server from the store.
+func (c *Client) deleteSession(ctx context.Context, srv *server) error {
+	if c.store == nil {
+		return nil
+	}
+	return c.store.Delete(ctx, c.sessionKey(srv))
+}
+
+// Restore sessions from the store, checking each with a ping. Returns true if any session was restored.
+// Sessions which are invalid, expired or rejected by the server are removed, leaving the client to login.
+// Sessions which could not be checked, such as when the server is unreachable, are kept for next time.
+// Servers are checked at the same time, so unreachable servers only delay the client by one timeout.
+func (c *Client) restoreSessions(ctx context.Context) bool {
+	if c.store == nil {
+		return false
+	}
+	var wg sync.WaitGroup
+	restored := make([]bool, len(c.servers))
+	for i, srv := range c.servers {
+		wg.Add(1)
+		go func(i int, srv *server) {
+			defer wg.Done()
+			ok, err := c.restoreSession(ctx, srv)
+			if err != nil || !ok {
+				c.clearCookies(srv)
+				if errors.Is(err, ErrInvalidSession) {
+					c.deleteSession(ctx, srv)
+				}
+				return
+			}
+			restored[i] = true
+		}(i, srv)
+	}
+	wg.Wait()
+	for _, ok := range restored {
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Restore the session with the server from the store, returning false if there is no session stored.
+// Sessions which should be removed from the store return ErrInvalidSession.
+func (c *Client) restoreSession(ctx context.Context, srv *server) (bool, error) {
+	data, err := c.store.Load(ctx, c.sessionKey(srv))
+	if err != nil || data == nil {
+		return false, err
+	}
+	var session storedSession
+	err = json.Unmarshal(data, &session)
+	if err != nil {
+		return false, fmt.Errorf("%w: %s", ErrInvalidSession, err)
+	}
+	if len(session.Cookies) == 0 || !session.Expires.IsZero() && time.Now().After(session.Expires) {
+		return false, fmt.Errorf("%w: expired", ErrInvalidSession)
+	}
+
+	// Add the cookies to the jar for the base path of the server.
+	u, err := sessionURL(srv)
+	if err != nil {
+		return false, err
+	}
+	base, err := url.Parse(srv.uriBase)
+	if err != nil {
+		return false, err
+	}
+	cookies := make([]*http.Cookie, len(session.Cookies))
+	for i, cookie := range session.Cookies {
+		cookies[i] = &http.Cookie{Name: cookie.Name, Value: cookie.Value, Path: base.Path}
+	}
+	c.client.Jar.SetCookies(u, cookies)
+
+	// Check the session is still accepted with a ping, sent without a version as it may not be negotiated yet.
+	res, err := c.sendRequest(ctx, srv, NewRequest("ping", []interface{}{}, map[string]interface{}{}))
+	if err != nil {
+		return false, err
+	}
+	res.Body.Close()
+	if res.StatusCode == http.StatusUnauthorized {
+		return false, fmt.Errorf("%w: rejected by server", ErrInvalidSession)
+	}
+	if res.StatusCode != http.StatusOK {
+		return false, unexpectedHTTPError(res)
+	}
+
+	// Use the session, keeping its expiry unless the ping provided a new one.
+	srv.recordLogin(nil)
+	if !session.Expires.IsZero() && srv.sessionExpires().IsZero() {
+		srv.setExpires(session.Expires)
+	}
+	return true, nil
+}
@@ -0,0 +1,198 @@
+package freeipa
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Session store which counts deleted sessions.
+type countingStore struct {
+	SessionStore
+	deletes int32
+}
+
+// Count the delete and delete the session.
+func (s *countingStore) Delete(ctx context.Context, key string) error {
+	atomic.AddInt32(&s.deletes, 1)
+	return s.SessionStore.Delete(ctx, key)
+}
+
+// Confirm sessions are saved encrypted, reused by new clients, and replaced when rejected.
+func TestSessionStore(t *testing.T) {
+	stub := &sessionStub{password: "testpassword"}
+	srv := stub.start()
+	defer srv.Close()
+
+	dir := t.TempDir()
+	key := bytes.Repeat([]byte{1}, 32)
+	store, err := NewFileSessionStore(dir, key)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	connectAs := func(user string, store SessionStore) *Client {
+		client, err := NewClient(
+			strings.TrimPrefix(srv.URL, "https://"),
+			WithTransport(srv.Client().Transport.(*http.Transport)),
+			WithAuthenticator(&PasswordAuthenticator{User: user, Password: "testpassword"}),
+			WithAPIVersion("2.245"),
+			WithSessionStore(store),
+		)
+		if err != nil {
+			t.Fatalf("error: %s", err)
+		}
+		return client
+	}
+	connect := func(store SessionStore) *Client {
+		return connectAs("test", store)
+	}
+
+	// The first client logs in and saves the session encrypted.
+	connect(store)
+	if atomic.LoadInt32(&stub.logins) != 1 {
+		t.Fatalf("expected login: %d", stub.logins)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*.session"))
+	if len(files) != 1 {
+		t.Fatalf("expected a stored session: %v", files)
+	}
+	data, _ := os.ReadFile(files[0])
+	if bytes.Contains(data, []byte("session-1")) {
+		t.Errorf("expected session to be encrypted")
+	}
+
+	// A new client reuses the session.
+	client := connect(store)
+	_, err = client.Do(NewRequest("ping", nil, nil))
+	if err != nil || atomic.LoadInt32(&stub.logins) != 1 {
+		t.Errorf("expected stored session to be used: %v %d", err, stub.logins)
+	}
+
+	// A store with the wrong key cannot decrypt the session, so the client logs in.
+	wrongKey, err := NewFileSessionStore(dir, bytes.Repeat([]byte{2}, 32))
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	connect(wrongKey)
+	if atomic.LoadInt32(&stub.logins) != 2 {
+		t.Errorf("expected login with wrong key: %d", stub.logins)
+	}
+
+	// A rejected session is replaced by logging in.
+	atomic.AddInt32(&stub.session, 1)
+	client = connect(store)
+	if atomic.LoadInt32(&stub.logins) != 3 {
+		t.Errorf("expected login after rejected session: %d", stub.logins)
+	}
+
+	// Closing keeps the stored session for the next client, such as the next run of a cron job.
+	err = client.Close()
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	client = connect(store)
+	_, err = client.Do(NewRequest("ping", nil, nil))
+	if err != nil || atomic.LoadInt32(&stub.logins) != 3 || atomic.LoadInt32(&stub.logouts) != 0 {
+		t.Errorf("expected session to be kept on close: %v %d %d", err, stub.logins, stub.logouts)
+	}
+
+	// A session which cannot be checked is kept, while one which cannot be decrypted is removed.
+	counting := &countingStore{SessionStore: store}
+	atomic.StoreInt32(&stub.unavailable, 1)
+	connect(counting)
+	atomic.StoreInt32(&stub.unavailable, 0)
+	if atomic.LoadInt32(&counting.deletes) != 0 {
+		t.Errorf("expected session to be kept when the server is unavailable: %d", counting.deletes)
+	}
+	counting.SessionStore = wrongKey
+	connect(counting)
+	if atomic.LoadInt32(&counting.deletes) != 1 {
+		t.Errorf("expected undecryptable session to be removed: %d", counting.deletes)
+	}
+
+	// Clients logging in as different users keep separate sessions in the same store.
+	client = connect(store)
+	connectAs("other", store)
+	files, _ = filepath.Glob(filepath.Join(dir, "*.session"))
+	if len(files) != 2 {
+		t.Errorf("expected a stored session for each user: %v", files)
+	}
+
+	// Logging out deletes the stored session.
+	err = client.Logout(context.Background())
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	files, _ = filepath.Glob(filepath.Join(dir, "*.session"))
+	if len(files) != 1 {
+		t.Errorf("expected stored session to be deleted: %v", files)
+	}
+}
+
+// Confirm stored sessions are checked with each server at the same time, so slow servers do not add up.
+func TestRestoreSessionsConcurrently(t *testing.T) {
+	const delay = 300 * time.Millisecond
+	var slow int32
+	stubs := make([]*sessionStub, 3)
+	var hosts []string
+	for i := range stubs {
+		stub := &sessionStub{password: "testpassword"}
+		stubs[i] = stub
+		mux := http.NewServeMux()
+		mux.HandleFunc("/ipa/session/login_password", stub.handleLogin)
+		mux.HandleFunc("/ipa/session/json", func(w http.ResponseWriter, req *http.Request) {
+			if atomic.LoadInt32(&slow) != 0 {
+				time.Sleep(delay)
+			}
+			stub.handleJSON(w, req)
+		})
+		srv := httptest.NewTLSServer(mux)
+		defer srv.Close()
+		hosts = append(hosts, strings.TrimPrefix(srv.URL, "https://"))
+	}
+
+	// Store the current session of each server.
+	store, err := NewFileSessionStore(t.TempDir(), bytes.Repeat([]byte{1}, 32))
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	ctx := context.Background()
+	for _, host := range hosts {
+		err = store.Save(ctx, "test https://"+host+DefaultBasePath, []byte(`{"cookies": [{"name": "ipa_session", "value": "session-0"}]}`))
+		if err != nil {
+			t.Fatalf("error: %s", err)
+		}
+	}
+
+	// Each server is slow to reply to the ping checking its session.
+	atomic.StoreInt32(&slow, 1)
+	start := time.Now()
+	client, err := NewClient("",
+		WithServers(hosts...),
+		WithTransport(&http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}),
+		WithAuthenticator(&PasswordAuthenticator{User: "test", Password: "testpassword"}),
+		WithAPIVersion("2.245"),
+		WithSessionStore(store),
+	)
+	elapsed := time.Since(start)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	defer client.Close()
+	if elapsed >= 2*delay {
+		t.Errorf("expected sessions to be checked at the same time: %s", elapsed)
+	}
+	for i, stub := range stubs {
+		if atomic.LoadInt32(&stub.logins) != 0 || !client.Servers()[i].LoggedIn {
+			t.Errorf("expected stored session to be restored for server %d: %d", i, stub.logins)
+		}
+	}
+}
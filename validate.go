@@ -0,0 +1,206 @@
+package freeipa
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Methods which are not checked against the schema, as their parameters are other requests.
+var unvalidatedMethods = map[string]bool{
+	"batch": true,
+}
+
+// Type of date/time values after encoding.
+var dateTimeType = reflect.TypeOf(DateTime{})
+
+// Check a prepared request against the schema, returning the error the server would return for an invalid request.
+func (c *Client) validateRequest(ctx context.Context, req *Request) error {
+	if unvalidatedMethods[req.Method] {
+		return nil
+	}
+	schema, err := c.Schema(ctx)
+	if err != nil {
+		return fmt.Errorf("error getting schema for validation: %w", err)
+	}
+	return validateRequest(schema, req)
+}
+
+// Check a prepared request against the schema.
+func validateRequest(schema *Schema, req *Request) error {
+	command := schema.Command(req.Method)
+	if command == nil {
+		return newError(CommandErrorCode, fmt.Sprintf("unknown command '%s'", req.Method), map[string]interface{}{"name": req.Method})
+	}
+	args, _ := req.Params[0].([]interface{})
+	options, _ := req.Params[1].(map[string]interface{})
+
+	// Split the parameters into positional arguments and options.
+	var argParams []*SchemaParam
+	params := make(map[string]*SchemaParam)
+	for _, param := range command.Params {
+		if param.Positional {
+			argParams = append(argParams, param)
+		}
+		params[param.Name] = param
+	}
+
+	// A multivalue last argument takes all remaining values, otherwise there is a maximum.
+	values := make(map[string]interface{})
+	for i, arg := range args {
+		if i >= len(argParams) {
+			return newError(MaxArgumentErrorCode, maxArgumentMessage(command.Name, len(argParams)), map[string]interface{}{"name": command.Name, "count": len(argParams)})
+		}
+		param := argParams[i]
+		if param.Multivalue && i == len(argParams)-1 && len(args) > len(argParams) {
+			values[param.Name] = args[i:]
+			break
+		}
+		values[param.Name] = arg
+	}
+
+	// Options must be known, arguments may also be provided by name.
+	for name, value := range options {
+		if name == "version" {
+			continue
+		}
+		if _, ok := params[name]; !ok {
+			return newError(OptionErrorCode, fmt.Sprintf("Unknown option: %s", name), map[string]interface{}{"option": name})
+		}
+		values[name] = value
+	}
+
+	// Check each parameter in schema order, so the first error is consistent.
+	for _, param := range command.Params {
+		value, ok := values[param.Name]
+		if ok {
+			value, ok = derefValue(value)
+		}
+		if !ok {
+			if param.IsRequired() {
+				return newError(RequirementErrorCode, fmt.Sprintf("'%s' is required", param.Name), map[string]interface{}{"name": param.Name})
+			}
+			continue
+		}
+		msg := checkParamValue(param, value)
+		if msg != "" {
+			return newError(ConversionErrorCode, fmt.Sprintf("invalid '%s': %s", param.Name, msg), map[string]interface{}{"name": param.Name, "error": msg})
+		}
+	}
+	return nil
+}
+
+// Message for too many arguments, as the server words it.
+func maxArgumentMessage(name string, count int) string {
+	if count == 1 {
+		return fmt.Sprintf("command '%s' takes at most %d argument", name, count)
+	}
+	return fmt.Sprintf("command '%s' takes at most %d arguments", name, count)
+}
+
+// Follow pointers to the value, returning false if it is nil.
+func derefValue(value interface{}) (interface{}, bool) {
+	rv := reflect.ValueOf(value)
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil, false
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return nil, false
+	}
+	return rv.Interface(), true
+}
+
+// Check the value is valid for the parameter, returning the conversion error message if not.
+func checkParamValue(param *SchemaParam, value interface{}) string {
+	rv := reflect.ValueOf(value)
+
+	// Lists are only allowed for multivalue parameters, and each value must be valid.
+	if (rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array) && rv.Type().Elem().Kind() != reflect.Uint8 {
+		if !param.Multivalue {
+			return "Only one value is allowed"
+		}
+		for i := 0; i < rv.Len(); i++ {
+			v, ok := derefValue(rv.Index(i).Interface())
+			if !ok {
+				continue
+			}
+			msg := checkValueType(param.Type, reflect.ValueOf(v))
+			if msg != "" {
+				return msg
+			}
+		}
+		return ""
+	}
+	return checkValueType(param.Type, rv)
+}
+
+// Check a single value is valid for the parameter type, returning the conversion error message if not.
+// Strings are accepted for most types, as the server converts them.
+func checkValueType(typ string, rv reflect.Value) string {
+	switch typ {
+	case "str", "unicode", "Str", "IA5Str", "Password", "Principal", "DNSName", "CertificateSigningRequest":
+		if rv.Kind() != reflect.String {
+			return "must be Unicode text"
+		}
+	case "int", "Int":
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		case reflect.Float32, reflect.Float64:
+			if rv.Float() != float64(int64(rv.Float())) {
+				return "must be an integer"
+			}
+		case reflect.String:
+			_, err := strconv.ParseInt(strings.TrimSpace(rv.String()), 10, 64)
+			if err != nil {
+				return "must be an integer"
+			}
+		default:
+			return "must be an integer"
+		}
+	case "Decimal", "float":
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+		case reflect.String:
+			_, err := strconv.ParseFloat(strings.TrimSpace(rv.String()), 64)
+			if err != nil {
+				return "must be a decimal number"
+			}
+		default:
+			return "must be a decimal number"
+		}
+	case "bool", "Bool", "Flag":
+		switch rv.Kind() {
+		case reflect.Bool:
+		case reflect.String:
+			_, err := strconv.ParseBool(strings.ToLower(rv.String()))
+			if err != nil {
+				return "must be True or False"
+			}
+		default:
+			return "must be True or False"
+		}
+	case "datetime", "DateTime":
+		// The server accepts several formats for dates in strings.
+		if rv.Type() != dateTimeType && rv.Type() != timeType && rv.Kind() != reflect.String {
+			return "must be a date/time value"
+		}
+	case "bytes", "Bytes", "Certificate":
+		// Binary values are byte slices, and strings are accepted as base64.
+		if rv.Kind() != reflect.String && !(rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8) {
+			return "must be binary data"
+		}
+	case "dict":
+		if rv.Kind() != reflect.Map && rv.Kind() != reflect.Struct {
+			return "must be a dictionary"
+		}
+	}
+	return ""
+}
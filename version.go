@@ -0,0 +1,77 @@
+package freeipa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+)
+
+// API version used when the server's version cannot be determined.
+const DefaultAPIVersion = "2.237"
+
+// Matches the API version in the ping summary, such as "IPA server version 4.9.8. API version 2.245".
+var pingAPIVersionRegexp = regexp.MustCompile(`API version ([0-9.]+)`)
+
+// How the API version sent with requests was chosen.
+type APIVersionSource int
+
+// API version sources.
+const (
+	// The version was provided with WithAPIVersion.
+	APIVersionConfigured APIVersionSource = iota
+	// The version was provided by the server in response to ping.
+	APIVersionNegotiated
+	// The server did not provide its version, so DefaultAPIVersion is used.
+	APIVersionFallback
+)
+
+// Ask the server for its API version using ping, falling back to the default API version if the server
+// rejects the ping or does not provide its version. Other failures, such as login, TLS or network errors, are returned.
+// Ping is sent without a version, which the server accepts as its own version.
+func (c *Client) negotiateAPIVersion(ctx context.Context) error {
+	c.apiVersion = DefaultAPIVersion
+	c.apiVersionSource = APIVersionFallback
+
+	// Send ping to the first available server.
+	var resp *Response
+	err := c.eachServer(ctx, true, func(ctx context.Context, srv *server) error {
+		var err error
+		resp, err = c.doWithServer(ctx, srv, NewRequest("ping", []interface{}{}, map[string]interface{}{}))
+		return err
+	})
+
+	// Older servers may reject ping sent this way with an API error, any other error is not from the API.
+	var ipaErr *Error
+	if errors.As(err, &ipaErr) && ipaErr.StatusCode == http.StatusOK {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error negotiating API version: %w", err)
+	}
+	c.serverVersion = resp.Version
+
+	// Use the API version from the summary if provided.
+	match := pingAPIVersionRegexp.FindStringSubmatch(resp.Result.Summary)
+	if match != nil {
+		c.apiVersion = match[1]
+		c.apiVersionSource = APIVersionNegotiated
+	}
+	return nil
+}
+
+// Get the API version sent with requests.
+func (c *Client) APIVersion() string {
+	return c.apiVersion
+}
+
+// Get the version of the server at the time the API version was negotiated.
+func (c *Client) ServerVersion() string {
+	return c.serverVersion
+}
+
+// Get how the API version sent with requests was chosen.
+func (c *Client) APIVersionSource() APIVersionSource {
+	return c.apiVersionSource
+}